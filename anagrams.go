@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// AnagramGroup is a set of distinct words made of the same letters
+type AnagramGroup struct {
+	Words []string // In alphabetical order
+	Count int      // Occurrences of all the words together
+}
+
+// anagramGroups groups the words in counts by their sorted runes, keeping
+// the groups with more than one word. The biggest groups come first, then
+// the most frequent, with ties in order of their first word.
+func anagramGroups(counts map[string]int) []AnagramGroup {
+	bySignature := make(map[string]*AnagramGroup)
+	for word, count := range counts {
+		signature := sortedRunes(word)
+		group, ok := bySignature[signature]
+		if !ok {
+			group = &AnagramGroup{}
+			bySignature[signature] = group
+		}
+		group.Words = append(group.Words, word)
+		group.Count += count
+	}
+
+	var groups []AnagramGroup
+	for _, group := range bySignature {
+		if len(group.Words) > 1 {
+			sort.Strings(group.Words)
+			groups = append(groups, *group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		gi, gj := groups[i], groups[j]
+		if len(gi.Words) != len(gj.Words) {
+			return len(gi.Words) > len(gj.Words)
+		}
+		if gi.Count != gj.Count {
+			return gi.Count > gj.Count
+		}
+		return gi.Words[0] < gj.Words[0]
+	})
+	return groups
+}
+
+// sortedRunes returns the runes of word in order, which anagrams share
+func sortedRunes(word string) string {
+	runes := []rune(word)
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return string(runes)
+}
+
+// runAnagrams prints the anagram groups among the words of each input,
+// normalized as word frequency analysis normalizes them
+func runAnagrams(stdin io.Reader, cfg *Config) error {
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		counts, err := analyze.WordCounts(r, frequencyOptions(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to read words: %w", err)
+		}
+		groups := anagramGroups(counts)
+		if cfg.FrequencyLimit > 0 && len(groups) > cfg.FrequencyLimit {
+			groups = groups[:cfg.FrequencyLimit]
+		}
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		printAnagramGroups(cfg, groups)
+		return nil
+	})
+}
+
+// printAnagramGroups prints each group's total count and its words
+func printAnagramGroups(cfg *Config, groups []AnagramGroup) {
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for _, g := range groups {
+			fmt.Fprintf(cfg.Output, "%s %s\n", formatCount(cfg, g.Count), strings.Join(g.Words, " "))
+		}
+		return
+	}
+
+	fmt.Fprintf(cfg.Output, "Anagram groups (sorted by size):\n")
+	fmt.Fprintf(cfg.Output, "%6s  %s\n", "Count", "Words")
+	for _, g := range groups {
+		fmt.Fprintf(cfg.Output, "%6s  %s\n", formatCount(cfg, g.Count), strings.Join(g.Words, ", "))
+	}
+}
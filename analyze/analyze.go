@@ -0,0 +1,1041 @@
+// Package analyze provides the text analysis behind the lexo command:
+// word, line, character and sentence counting, word frequency analysis,
+// language detection and code line statistics.
+package analyze
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CountWords counts whitespace-separated words
+func CountWords(r io.Reader) int {
+	return CountWordsMode(r, WordsWhitespace)
+}
+
+// CountWordsMode counts words separated as mode selects
+func CountWordsMode(r io.Reader, mode WordMode) int {
+	return CountWordsSplit(r, mode.SplitFunc())
+}
+
+// CountWordsSplit counts the words split finds, such as a WordMode's
+// SplitFunc or CJKSplitFunc
+func CountWordsSplit(r io.Reader, split bufio.SplitFunc) int {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(split)
+
+	wc := 0
+	for scanner.Scan() {
+		wc++
+	}
+
+	return wc
+}
+
+// CountLines counts lines
+func CountLines(r io.Reader) int {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	lc := 0
+	for scanner.Scan() {
+		lc++
+	}
+
+	return lc
+}
+
+// CountChars counts characters (Unicode runes)
+func CountChars(r io.Reader) int {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanRunes)
+
+	cc := 0
+	for scanner.Scan() {
+		cc++
+	}
+
+	return cc
+}
+
+// CountCharsTabs counts characters like CountChars, except that each tab
+// counts as the columns it spans to the next multiple of tabWidth. A tabWidth
+// of 0 or less counts tabs as one character.
+func CountCharsTabs(r io.Reader, tabWidth int) int {
+	if tabWidth <= 0 {
+		return CountChars(r)
+	}
+
+	reader := bufio.NewReader(r)
+
+	cc, column := 0, 0
+	for {
+		ch, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+
+		switch ch {
+		case '\n':
+			cc++
+			column = 0
+		case '\t':
+			next := nextTabStop(column, tabWidth)
+			cc += next - column
+			column = next
+		default:
+			cc++
+			column++
+		}
+	}
+
+	return cc
+}
+
+// nextTabStop returns the column a tab at column moves to with tab stops
+// every tabWidth columns
+func nextTabStop(column, tabWidth int) int {
+	return (column/tabWidth + 1) * tabWidth
+}
+
+// CountBytes counts raw bytes, which differs from CountChars for multibyte UTF-8 text
+func CountBytes(r io.Reader) int {
+	n, _ := io.Copy(io.Discard, r)
+	return int(n)
+}
+
+// MaxLineLength returns the display width of the longest line like wc -L,
+// counting runes rather than bytes and expanding tabs to the next multiple
+// of 8. Carriage returns take no width so CRLF text measures the same as LF.
+func MaxLineLength(r io.Reader) int {
+	return MaxLineLengthTabs(r, 8)
+}
+
+// MaxLineLengthTabs measures the longest line like MaxLineLength, with tab
+// stops every tabWidth columns instead of 8. A tabWidth of 0 or less uses 8.
+func MaxLineLengthTabs(r io.Reader, tabWidth int) int {
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
+
+	reader := bufio.NewReader(r)
+
+	longest, width := 0, 0
+	for {
+		ch, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+
+		switch ch {
+		case '\n':
+			width = 0
+		case '\r':
+		case '\t':
+			width = nextTabStop(width, tabWidth)
+		default:
+			width++
+		}
+		if width > longest {
+			longest = width
+		}
+	}
+
+	return longest
+}
+
+// LineLengths returns the characters and lines in the text in one pass, for
+// averaging line lengths. Lines are counted like CountLines, and characters are
+// runes other than the line endings, so "ab\ncd" is 4 characters in 2 lines.
+func LineLengths(r io.Reader) (chars, lines int) {
+	reader := bufio.NewReader(r)
+
+	inLine := false
+	for {
+		ch, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+
+		switch ch {
+		case '\n':
+			lines++
+			inLine = false
+		case '\r':
+			// Part of a CRLF line ending, which CountLines strips too
+			inLine = true
+		default:
+			chars++
+			inLine = true
+		}
+	}
+
+	// Count a last line without a newline
+	if inLine {
+		lines++
+	}
+
+	return chars, lines
+}
+
+// CountSentences counts sentences terminated by '.', '!' or '?'.
+// Runs of terminators such as "..." or "?!" count as a single boundary,
+// and a trailing clause without terminal punctuation counts as a sentence.
+func CountSentences(r io.Reader) int {
+	reader := bufio.NewReader(r)
+
+	sc := 0
+	inSentence := false
+	for {
+		ch, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+
+		switch {
+		case ch == '.' || ch == '!' || ch == '?':
+			// Only the first terminator after some content ends a sentence
+			if inSentence {
+				sc++
+				inSentence = false
+			}
+		case !unicode.IsSpace(ch):
+			inSentence = true
+		}
+	}
+
+	// Count a final clause that wasn't terminated
+	if inSentence {
+		sc++
+	}
+
+	return sc
+}
+
+// SummaryStats holds every basic count of a text
+type SummaryStats struct {
+	Lines      int
+	Words      int
+	Chars      int
+	Bytes      int
+	Sentences  int
+	Paragraphs int
+}
+
+// Summary counts lines, words, characters, bytes, sentences and paragraphs
+// in a single pass, each counted the same way as CountLines, CountWords,
+// CountChars, CountBytes, CountSentences and Density. Words are split as
+// opts selects.
+func Summary(r io.Reader, opts FrequencyOptions) SummaryStats {
+	reader := bufio.NewReader(r)
+	words := newWordStarts(opts)
+
+	var stats SummaryStats
+	inSentence, inParagraph, lineBlank, inLine := false, false, true, false
+	for {
+		ch, size, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+		stats.Chars++
+		stats.Bytes += size
+
+		if ch == '\n' {
+			stats.Lines++
+			inLine = false
+
+			// A blank line ends the paragraph
+			if lineBlank {
+				inParagraph = false
+			}
+			lineBlank = true
+		} else {
+			inLine = true
+		}
+
+		if words.add(ch) {
+			stats.Words++
+		}
+		if unicode.IsSpace(ch) {
+			continue
+		}
+
+		lineBlank = false
+		if !inParagraph {
+			inParagraph = true
+			stats.Paragraphs++
+		}
+
+		// Only the first terminator after some content ends a sentence
+		if ch == '.' || ch == '!' || ch == '?' {
+			if inSentence {
+				stats.Sentences++
+				inSentence = false
+			}
+		} else {
+			inSentence = true
+		}
+	}
+
+	// Count a last line without a newline and a final clause that wasn't terminated
+	if inLine {
+		stats.Lines++
+	}
+	if inSentence {
+		stats.Sentences++
+	}
+
+	return stats
+}
+
+// FrequencyOptions controls how words are normalized and filtered before counting
+type FrequencyOptions struct {
+	FilterStopwords   bool     // Skip common English words listed in Stopwords
+	MinCount          int      // Only report words appearing at least this many times
+	IncludeWhitespace bool     // Count whitespace runes in character frequency
+	CaseSensitive     bool     // Keep case so "US" and "us" are counted separately
+	TrimChars         string   // Characters trimmed from word ends instead of the default punctuation
+	NoTrim            bool     // Count words verbatim without trimming anything
+	Reverse           bool     // Invert the sort order before the limit is applied
+	WordMode          WordMode // What separates words; whitespace when empty
+	CJK               bool     // Count each Han and Kana character as a word of its own
+}
+
+// splitFunc returns the bufio.SplitFunc that splits text into words as opts selects
+func (opts FrequencyOptions) splitFunc() bufio.SplitFunc {
+	if opts.CJK {
+		return opts.WordMode.CJKSplitFunc()
+	}
+	return opts.WordMode.SplitFunc()
+}
+
+// wordStarts counts words a rune at a time, for counters that make a single
+// pass over the input, splitting them as opts.splitFunc does
+type wordStarts struct {
+	inWord func(rune) bool
+	alone  func(rune) bool
+	in     bool
+}
+
+func newWordStarts(opts FrequencyOptions) *wordStarts {
+	inWord := opts.WordMode.inWord()
+	if !opts.CJK {
+		return &wordStarts{inWord: inWord, alone: func(rune) bool { return false }}
+	}
+	return &wordStarts{
+		inWord: func(r rune) bool { return inWord(r) && !isCJKPunct(r) },
+		alone:  IsCJK,
+	}
+}
+
+// add reports whether r starts a new word
+func (ws *wordStarts) add(r rune) bool {
+	switch {
+	case ws.alone(r):
+		ws.in = false
+		return true
+	case ws.inWord(r):
+		started := !ws.in
+		ws.in = true
+		return started
+	}
+	ws.in = false
+	return false
+}
+
+// WordMode selects what separates one word from the next
+type WordMode string
+
+const (
+	WordsWhitespace WordMode = "whitespace" // Runs of non-space characters; the empty mode splits this way too
+	WordsAlpha      WordMode = "alpha"      // Runs of letters, so "don't" is "don" and "t"
+	WordsAlnum      WordMode = "alnum"      // Runs of letters and digits
+)
+
+// SplitFunc returns the bufio.SplitFunc that splits text into words as m selects
+func (m WordMode) SplitFunc() bufio.SplitFunc {
+	if m == WordsAlpha || m == WordsAlnum {
+		return scanRuns(m.inWord(), nil)
+	}
+	return bufio.ScanWords
+}
+
+// CJKSplitFunc returns a bufio.SplitFunc that splits text as SplitFunc does,
+// except that each Han, Hiragana and Katakana character is a word of its
+// own. Chinese and Japanese aren't written with spaces between words, so
+// counting characters is a common approximation.
+func (m WordMode) CJKSplitFunc() bufio.SplitFunc {
+	inWord := m.inWord()
+	return scanRuns(func(r rune) bool {
+		// Full stops and commas like 。 and ， separate words like spaces do
+		return inWord(r) && !isCJKPunct(r)
+	}, IsCJK)
+}
+
+// inWord returns whether a rune is part of a word, rather than a separator, in mode m
+func (m WordMode) inWord() func(rune) bool {
+	switch m {
+	case WordsAlpha:
+		return unicode.IsLetter
+	case WordsAlnum:
+		return func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r)
+		}
+	default:
+		return func(r rune) bool {
+			return !unicode.IsSpace(r)
+		}
+	}
+}
+
+// IsCJK reports whether r is a Han, Hiragana or Katakana character,
+// counting the ー that lengthens a kana's sound
+func IsCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// isCJKPunct reports whether r is punctuation from the CJK Symbols and
+// Punctuation or Halfwidth and Fullwidth Forms blocks
+func isCJKPunct(r rune) bool {
+	return unicode.IsPunct(r) && (r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xffef)
+}
+
+// scanRuns returns a bufio.SplitFunc like bufio.ScanWords that yields each
+// run of runes for which inWord is true, skipping everything else. Runes for
+// which alone is true are yielded one at a time; alone may be nil.
+func scanRuns(inWord, alone func(rune) bool) bufio.SplitFunc {
+	if alone == nil {
+		alone = func(rune) bool { return false }
+	}
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		// Skip leading separators, waiting for more data to finish a split rune
+		start := 0
+		for start < len(data) {
+			if !atEOF && !utf8.FullRune(data[start:]) {
+				return start, nil, nil
+			}
+			r, width := utf8.DecodeRune(data[start:])
+			if alone(r) {
+				return start + width, data[start : start+width], nil
+			}
+			if inWord(r) {
+				break
+			}
+			start += width
+		}
+
+		// Scan until a separator, marking the end of the word
+		for i := start; i < len(data); {
+			if !atEOF && !utf8.FullRune(data[i:]) {
+				return start, nil, nil
+			}
+			r, width := utf8.DecodeRune(data[i:])
+			if alone(r) {
+				// Leave the rune to be the next word
+				return i, data[start:i], nil
+			}
+			if !inWord(r) {
+				return i + width, data[start:i], nil
+			}
+			i += width
+		}
+
+		// The word runs to the end of the input
+		if atEOF && len(data) > start {
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	}
+}
+
+// Stopwords is the built-in list of common English words
+// skipped by frequency analysis when stopword filtering is enabled
+var Stopwords = map[string]bool{
+	"a": true, "about": true, "above": true, "after": true, "again": true,
+	"against": true, "all": true, "am": true, "an": true, "and": true,
+	"any": true, "are": true, "as": true, "at": true, "be": true,
+	"because": true, "been": true, "before": true, "being": true, "below": true,
+	"between": true, "both": true, "but": true, "by": true, "can": true,
+	"could": true, "did": true, "do": true, "does": true, "doing": true,
+	"down": true, "during": true, "each": true, "few": true, "for": true,
+	"from": true, "further": true, "had": true, "has": true, "have": true,
+	"having": true, "he": true, "her": true, "here": true, "hers": true,
+	"herself": true, "him": true, "himself": true, "his": true, "how": true,
+	"i": true, "if": true, "in": true, "into": true, "is": true,
+	"it": true, "its": true, "itself": true, "just": true, "me": true,
+	"more": true, "most": true, "my": true, "myself": true, "no": true,
+	"nor": true, "not": true, "now": true, "of": true, "off": true,
+	"on": true, "once": true, "only": true, "or": true, "other": true,
+	"our": true, "ours": true, "ourselves": true, "out": true, "over": true,
+	"own": true, "same": true, "she": true, "should": true, "so": true,
+	"some": true, "such": true, "than": true, "that": true, "the": true,
+	"their": true, "theirs": true, "them": true, "themselves": true, "then": true,
+	"there": true, "these": true, "they": true, "this": true, "those": true,
+	"through": true, "to": true, "too": true, "under": true, "until": true,
+	"up": true, "very": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true,
+	"who": true, "whom": true, "why": true, "will": true, "with": true,
+	"would": true, "you": true, "your": true, "yours": true, "yourself": true,
+	"yourselves": true,
+}
+
+// wordPunctuation is the punctuation trimmed from the ends of each word
+const wordPunctuation = ".,;:!?\"'()[]{}"
+
+// NormalizeWord lowercases a word and trims surrounding punctuation
+// so that "The" and "the," are counted as the same word. Case is kept
+// when opts.CaseSensitive is set, and opts.TrimChars and opts.NoTrim
+// change or disable the trimming.
+// It returns an empty string for words that should be skipped.
+func NormalizeWord(word string, opts FrequencyOptions) string {
+	// Convert to lowercase for case-insensitive counting
+	if !opts.CaseSensitive {
+		word = strings.ToLower(word)
+	}
+
+	// Remove any punctuation at the start or end of the word
+	if !opts.NoTrim {
+		cutset := wordPunctuation
+		if opts.TrimChars != "" {
+			cutset = opts.TrimChars
+		}
+		word = strings.Trim(word, cutset)
+	}
+
+	// Drop Stopwords if requested, whatever their case
+	if opts.FilterStopwords && Stopwords[strings.ToLower(word)] {
+		return ""
+	}
+
+	return word
+}
+
+// Tokens scans the text and calls fn with each normalized word in the order
+// they appear
+func Tokens(r io.Reader, opts FrequencyOptions, fn func(word string)) error {
+	// Create a scanner to read words
+	scanner := bufio.NewScanner(r)
+	scanner.Split(opts.splitFunc())
+
+	// Process each word
+	for scanner.Scan() {
+		word := NormalizeWord(scanner.Text(), opts)
+
+		// Skip empty strings after trimming
+		if word == "" {
+			continue
+		}
+
+		fn(word)
+	}
+
+	return scanner.Err()
+}
+
+// WordCounts scans the text and counts each normalized word
+func WordCounts(r io.Reader, opts FrequencyOptions) (map[string]int, error) {
+	// Use a map to count word frequencies
+	wordCounts := make(map[string]int)
+
+	err := Tokens(r, opts, func(word string) {
+		wordCounts[word]++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wordCounts, nil
+}
+
+// AffixCounts counts the first n runes (with suffix false) or last n runes
+// (with suffix true) of each normalized word, skipping words shorter than n
+func AffixCounts(r io.Reader, n int, suffix bool, opts FrequencyOptions) (map[string]int, error) {
+	affixCounts := make(map[string]int)
+
+	err := Tokens(r, opts, func(word string) {
+		runes := []rune(word)
+		if len(runes) < n {
+			return
+		}
+		if suffix {
+			affixCounts[string(runes[len(runes)-n:])]++
+		} else {
+			affixCounts[string(runes[:n])]++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return affixCounts, nil
+}
+
+// WordPositions counts each normalized word like WordCounts, also returning
+// where each word first appears as its 1-based index among the words counted
+func WordPositions(r io.Reader, opts FrequencyOptions) (counts map[string]int, firstSeen map[string]int, err error) {
+	counts = make(map[string]int)
+	firstSeen = make(map[string]int)
+
+	position := 0
+	err = Tokens(r, opts, func(word string) {
+		position++
+		if _, seen := firstSeen[word]; !seen {
+			firstSeen[word] = position
+		}
+		counts[word]++
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return counts, firstSeen, nil
+}
+
+// WordStats returns the average word length in characters along with the
+// longest and shortest words, split as opts selects and trimmed of surrounding
+// punctuation as frequency analysis does. Ties keep the first word
+// encountered, and empty input yields zero and empty strings.
+func WordStats(r io.Reader, opts FrequencyOptions) (avg float64, longest, shortest string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(opts.splitFunc())
+
+	var words, totalLen, longestLen, shortestLen int
+	for scanner.Scan() {
+		word := strings.Trim(scanner.Text(), wordPunctuation)
+		if word == "" {
+			continue
+		}
+
+		n := utf8.RuneCountInString(word)
+		if words == 0 || n > longestLen {
+			longest, longestLen = word, n
+		}
+		if words == 0 || n < shortestLen {
+			shortest, shortestLen = word, n
+		}
+		words++
+		totalLen += n
+	}
+
+	if words == 0 {
+		return 0, "", ""
+	}
+	return float64(totalLen) / float64(words), longest, shortest
+}
+
+// CapsStats counts the words that are ALL-CAPS, Capitalized and lowercase,
+// looking only at each word's letters so "NASA's" is all caps and "hello,"
+// is lowercase. A word is all caps when it has more than one letter and every
+// cased letter is uppercase; otherwise its first cased letter decides, so
+// "iPhone" is lowercase and "McDonald" is capitalized. Words without cased
+// letters, like numbers, symbols or Chinese, aren't counted. Words are split
+// as opts selects.
+func CapsStats(r io.Reader, opts FrequencyOptions) (allCaps, capitalized, lower int) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(opts.splitFunc())
+
+	for scanner.Scan() {
+		var first rune
+		letters, upper, cased := 0, 0, 0
+		for _, ch := range scanner.Text() {
+			if !unicode.IsLetter(ch) {
+				continue
+			}
+			letters++
+			isUpper := unicode.IsUpper(ch) || unicode.IsTitle(ch)
+			if !isUpper && !unicode.IsLower(ch) {
+				continue
+			}
+			if cased == 0 {
+				first = ch
+			}
+			cased++
+			if isUpper {
+				upper++
+			}
+		}
+
+		switch {
+		case cased == 0:
+		case letters > 1 && upper == cased:
+			allCaps++
+		case unicode.IsLower(first):
+			lower++
+		default:
+			capitalized++
+		}
+	}
+
+	return allCaps, capitalized, lower
+}
+
+// WordLengthHistogram counts how many words there are of each length in
+// characters, split as opts selects and trimmed as the frequency analysis does
+func WordLengthHistogram(r io.Reader, opts FrequencyOptions) map[int]int {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(opts.splitFunc())
+
+	histogram := make(map[int]int)
+	for scanner.Scan() {
+		word := NormalizeWord(scanner.Text(), FrequencyOptions{})
+		if word == "" {
+			continue
+		}
+		histogram[utf8.RuneCountInString(word)]++
+	}
+
+	return histogram
+}
+
+// CountUniqueWords counts the distinct words in the text, using the
+// same splitting and normalization as the frequency analysis with opts
+func CountUniqueWords(r io.Reader, opts FrequencyOptions) int {
+	wordCounts, _ := WordCounts(r, opts)
+	return len(wordCounts)
+}
+
+// WordFrequency represents a word and its frequency count
+type WordFrequency struct {
+	Word  string
+	Count int
+}
+
+// SortMode selects the order frequency results are returned in
+type SortMode string
+
+const (
+	SortAlpha  SortMode = "alpha"  // Alphabetically; the empty mode sorts this way too
+	SortCount  SortMode = "count"  // Most frequent first
+	SortLength SortMode = "length" // Longest first, measured in runes
+)
+
+// WordFrequencies counts the frequency of each word in the text
+// and returns the results sorted as mode selects
+func WordFrequencies(r io.Reader, mode SortMode, limit int, opts FrequencyOptions) ([]WordFrequency, error) {
+	// If limit is 0 or negative, set a reasonable default
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// Count each normalized word
+	wordCounts, err := WordCounts(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return SortFrequencies(wordCounts, mode, limit, opts), nil
+}
+
+// NgramFrequencies counts the frequency of each sequence of n contiguous
+// normalized words, joined with a space. An n of 1 or less falls back to
+// single-word frequency, and texts shorter than n words yield no results.
+func NgramFrequencies(r io.Reader, n int, mode SortMode, limit int, opts FrequencyOptions) ([]WordFrequency, error) {
+	// If limit is 0 or negative, set a reasonable default
+	if limit <= 0 {
+		limit = 10
+	}
+
+	ngramCounts, err := NgramCounts(r, n, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return SortFrequencies(ngramCounts, mode, limit, opts), nil
+}
+
+// NgramCounts counts each sequence of n contiguous normalized words, joined
+// with a space. An n of 1 or less counts single words like WordCounts.
+func NgramCounts(r io.Reader, n int, opts FrequencyOptions) (map[string]int, error) {
+	if n <= 1 {
+		return WordCounts(r, opts)
+	}
+
+	// Create a scanner to read words
+	scanner := bufio.NewScanner(r)
+	scanner.Split(opts.splitFunc())
+
+	// Slide a window of n words over the normalized word stream
+	ngramCounts := make(map[string]int)
+	window := make([]string, 0, n)
+	for scanner.Scan() {
+		word := NormalizeWord(scanner.Text(), opts)
+		if word == "" {
+			continue
+		}
+
+		if len(window) == n {
+			window = append(window[:0], window[1:]...)
+		}
+		window = append(window, word)
+
+		if len(window) == n {
+			ngramCounts[strings.Join(window, " ")]++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ngramCounts, nil
+}
+
+// SortFrequencies converts counts to a slice sorted as mode selects, breaking
+// ties alphabetically, dropping words below the minimum count and then
+// truncating to limit entries
+func SortFrequencies(wordCounts map[string]int, mode SortMode, limit int, opts FrequencyOptions) []WordFrequency {
+	less := frequencyLess(mode, opts.Reverse)
+
+	// Apply the minimum count before the limit so the limit counts only eligible words
+	eligible := len(wordCounts)
+	if opts.MinCount > 0 {
+		eligible = 0
+		for _, count := range wordCounts {
+			if count >= opts.MinCount {
+				eligible++
+			}
+		}
+	}
+
+	// A limit smaller than the number of words only needs the top entries,
+	// which a heap finds without sorting all of them
+	if limit > 0 && limit < eligible {
+		return topFrequencies(wordCounts, limit, opts.MinCount, less)
+	}
+
+	// Convert map to slice for sorting
+	var frequencies []WordFrequency
+	for word, count := range wordCounts {
+		if count >= opts.MinCount {
+			frequencies = append(frequencies, WordFrequency{Word: word, Count: count})
+		}
+	}
+	sort.Slice(frequencies, func(i, j int) bool {
+		return less(frequencies[i], frequencies[j])
+	})
+
+	return frequencies
+}
+
+// frequencyLess returns the order mode sorts frequencies in, reversed if
+// asked. Ties are broken alphabetically, so no two words compare equal.
+func frequencyLess(mode SortMode, reverse bool) func(a, b WordFrequency) bool {
+	var less func(a, b WordFrequency) bool
+	switch mode {
+	case SortCount:
+		// Sort by count (descending) with alphabetical tiebreaker
+		less = func(a, b WordFrequency) bool {
+			if a.Count == b.Count {
+				return a.Word < b.Word
+			}
+			return a.Count > b.Count
+		}
+	case SortLength:
+		// Sort by length in runes (descending) with alphabetical tiebreaker
+		less = func(a, b WordFrequency) bool {
+			la := utf8.RuneCountInString(a.Word)
+			lb := utf8.RuneCountInString(b.Word)
+			if la == lb {
+				return a.Word < b.Word
+			}
+			return la > lb
+		}
+	default:
+		// Sort alphabetically
+		less = func(a, b WordFrequency) bool {
+			return a.Word < b.Word
+		}
+	}
+
+	if reverse {
+		return func(a, b WordFrequency) bool {
+			return less(b, a)
+		}
+	}
+	return less
+}
+
+// frequencyHeap keeps the entries that sort first, with the one that sorts
+// last at the root so it's the one replaced when a better entry comes along
+type frequencyHeap struct {
+	entries []WordFrequency
+	less    func(a, b WordFrequency) bool
+}
+
+func (h *frequencyHeap) Len() int           { return len(h.entries) }
+func (h *frequencyHeap) Less(i, j int) bool { return h.less(h.entries[j], h.entries[i]) }
+func (h *frequencyHeap) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *frequencyHeap) Push(x interface{}) { h.entries = append(h.entries, x.(WordFrequency)) }
+func (h *frequencyHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// topFrequencies returns the first limit words with at least minCount
+// occurrences in the order less sorts them, the same as sorting every word
+// and truncating, but only holding limit entries at a time
+func topFrequencies(wordCounts map[string]int, limit, minCount int, less func(a, b WordFrequency) bool) []WordFrequency {
+	h := &frequencyHeap{entries: make([]WordFrequency, 0, limit), less: less}
+	for word, count := range wordCounts {
+		if count < minCount {
+			continue
+		}
+		wf := WordFrequency{Word: word, Count: count}
+		if h.Len() < limit {
+			heap.Push(h, wf)
+		} else if less(wf, h.entries[0]) {
+			h.entries[0] = wf
+			heap.Fix(h, 0)
+		}
+	}
+
+	// Popping gives the entries last first
+	frequencies := make([]WordFrequency, h.Len())
+	for i := len(frequencies) - 1; i >= 0; i-- {
+		frequencies[i] = heap.Pop(h).(WordFrequency)
+	}
+	return frequencies
+}
+
+// CharFrequency represents a character and its frequency count
+type CharFrequency struct {
+	Char  rune
+	Count int
+}
+
+// CharFrequencies counts how often each rune appears in the text and returns
+// the results sorted by frequency (highest first) for SortCount, or by code
+// point for any other mode. Whitespace runes are skipped unless
+// opts.IncludeWhitespace is set.
+func CharFrequencies(r io.Reader, mode SortMode, limit int, opts FrequencyOptions) ([]CharFrequency, error) {
+	// If limit is 0 or negative, set a reasonable default
+	if limit <= 0 {
+		limit = 10
+	}
+
+	charCounts, err := CharCounts(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return SortCharFrequencies(charCounts, mode, limit, opts), nil
+}
+
+// CharCounts counts how often each rune appears in the text, skipping
+// whitespace runes unless opts.IncludeWhitespace is set
+func CharCounts(r io.Reader, opts FrequencyOptions) (map[rune]int, error) {
+	// Create a scanner to read runes
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanRunes)
+
+	charCounts := make(map[rune]int)
+	for scanner.Scan() {
+		c := []rune(scanner.Text())[0]
+		if !opts.IncludeWhitespace && unicode.IsSpace(c) {
+			continue
+		}
+		charCounts[c]++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return charCounts, nil
+}
+
+// SortCharFrequencies converts character counts to a slice sorted by count
+// (highest first) for SortCount or by code point otherwise, dropping characters
+// below the minimum count and then truncating to limit entries
+func SortCharFrequencies(charCounts map[rune]int, mode SortMode, limit int, opts FrequencyOptions) []CharFrequency {
+	// Convert map to slice for sorting
+	var frequencies []CharFrequency
+	for c, count := range charCounts {
+		if count >= opts.MinCount {
+			frequencies = append(frequencies, CharFrequency{Char: c, Count: count})
+		}
+	}
+
+	if mode == SortCount {
+		// Sort by count (descending) with code point tiebreaker
+		sort.Slice(frequencies, func(i, j int) bool {
+			if frequencies[i].Count == frequencies[j].Count {
+				return frequencies[i].Char < frequencies[j].Char
+			}
+			return frequencies[i].Count > frequencies[j].Count
+		})
+	} else {
+		sort.Slice(frequencies, func(i, j int) bool {
+			return frequencies[i].Char < frequencies[j].Char
+		})
+	}
+	if opts.Reverse {
+		for i, j := 0, len(frequencies)-1; i < j; i, j = i+1, j-1 {
+			frequencies[i], frequencies[j] = frequencies[j], frequencies[i]
+		}
+	}
+
+	// Apply limit
+	if limit > 0 && limit < len(frequencies) {
+		frequencies = frequencies[:limit]
+	}
+
+	return frequencies
+}
+
+// ShannonEntropy returns the Shannon entropy of the text in bits per
+// character, from how often each rune (whitespace included) appears.
+// Empty input has an entropy of 0.
+func ShannonEntropy(r io.Reader) float64 {
+	counts, _ := CharCounts(r, FrequencyOptions{IncludeWhitespace: true})
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		entropy += entropyTerm(count, total)
+	}
+	return entropy
+}
+
+// ByteEntropy returns the Shannon entropy of the input in bits per byte,
+// which is 8 for random data. Empty input has an entropy of 0.
+func ByteEntropy(r io.Reader) float64 {
+	var counts [256]int
+	total := 0
+	reader := bufio.NewReader(r)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			break
+		}
+		counts[b]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		entropy += entropyTerm(count, total)
+	}
+	return entropy
+}
+
+// entropyTerm returns -p*log2(p) for a symbol seen count times out of total,
+// or 0 for a symbol that never appears
+func entropyTerm(count, total int) float64 {
+	if count == 0 {
+		return 0
+	}
+	p := float64(count) / float64(total)
+	return -p * math.Log2(p)
+}
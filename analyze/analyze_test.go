@@ -0,0 +1,1183 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCountWords(t *testing.T) {
+	b := bytes.NewBufferString("word1 word2 word3 word4\n")
+
+	expected := 4
+	actual := CountWords(b)
+
+	if actual != expected {
+		t.Errorf("Expected %d, got %d.\n", expected, actual)
+	}
+}
+
+func TestWordModes(t *testing.T) {
+	text := "don't stop 42x well-known café—naïve 3.14"
+	testCases := []struct {
+		mode     WordMode
+		expected string
+	}{
+		{"", "don't stop 42x well-known café—naïve 3.14"},
+		{WordsWhitespace, "don't stop 42x well-known café—naïve 3.14"},
+		{WordsAlpha, "don t stop x well known café naïve"},
+		{WordsAlnum, "don t stop 42x well known café naïve 3 14"},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			// Reading a byte at a time splits the multibyte runes across reads
+			var words []string
+			scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(text)))
+			scanner.Split(tc.mode.SplitFunc())
+			for scanner.Scan() {
+				words = append(words, scanner.Text())
+			}
+			if actual := strings.Join(words, " "); actual != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, actual)
+			}
+			if actual := CountWordsMode(strings.NewReader(text), tc.mode); actual != len(words) {
+				t.Errorf("CountWordsMode: expected %d, got %d", len(words), actual)
+			}
+		})
+	}
+
+	// CJK characters are words of their own, whatever the mode
+	cjkTestCases := []struct {
+		mode     WordMode
+		text     string
+		expected string
+	}{
+		{"", "快速的棕色狐狸", "快 速 的 棕 色 狐 狸"},
+		{"", "Go语言 is 好", "Go 语 言 is 好"},
+		{WordsAlpha, "ひらがなとカタカナ, ok", "ひ ら が な と カ タ カ ナ ok"},
+		{WordsAlnum, "第3章", "第 3 章"},
+		{"", "한국어 text", "한국어 text"},
+		{"", "コーヒー。「好」，ok!", "コ ー ヒ ー 好 ok!"},
+	}
+	for _, tc := range cjkTestCases {
+		var words []string
+		scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(tc.text)))
+		scanner.Split(tc.mode.CJKSplitFunc())
+		for scanner.Scan() {
+			words = append(words, scanner.Text())
+		}
+		if actual := strings.Join(words, " "); actual != tc.expected {
+			t.Errorf("CJKSplitFunc(%q) for %q: expected %q, got %q", tc.mode, tc.text, tc.expected, actual)
+		}
+	}
+	if actual := CountWordsSplit(strings.NewReader("快速的 fox"), WordsWhitespace.CJKSplitFunc()); actual != 4 {
+		t.Errorf("CountWordsSplit: expected 4, got %d", actual)
+	}
+
+	// Frequency analysis splits words the same way
+	counts, err := WordCounts(strings.NewReader("Don't don't"), FrequencyOptions{WordMode: WordsAlpha})
+	if err != nil {
+		t.Fatalf("WordCounts returned error: %v", err)
+	}
+	if fmt.Sprint(counts) != "map[don:2 t:2]" {
+		t.Errorf("Expected don and t twice each, got %v", counts)
+	}
+	counts, err = WordCounts(strings.NewReader("狐狸 狐"), FrequencyOptions{CJK: true})
+	if err != nil {
+		t.Fatalf("WordCounts returned error: %v", err)
+	}
+	if fmt.Sprint(counts) != "map[狐:2 狸:1]" {
+		t.Errorf("Expected 狐 twice and 狸 once, got %v", counts)
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	b := bytes.NewBufferString("line1\nline2\nline3\nline4\n")
+
+	expected := 4
+	actual := CountLines(b)
+
+	if actual != expected {
+		t.Errorf("Expected %d, got %d.\n", expected, actual)
+	}
+}
+
+func TestCountChars(t *testing.T) {
+	b := bytes.NewBufferString("hello")
+
+	expected := 5
+	actual := CountChars(b)
+
+	if actual != expected {
+		t.Errorf("Expected %d, got %d.\n", expected, actual)
+	}
+}
+
+func TestCountBytes(t *testing.T) {
+	// "héllo" is 5 runes but 6 bytes in UTF-8
+	b := bytes.NewBufferString("héllo")
+
+	expected := 6
+	actual := CountBytes(b)
+
+	if actual != expected {
+		t.Errorf("Expected %d, got %d.\n", expected, actual)
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{"empty input", "", 0},
+		{"longest line wins", "ab\nabcd\nabc\n", 4},
+		{"last line without newline", "ab\nabcdef", 6},
+		{"runes not bytes", "héllo\n日本語\n", 5},
+		{"tab to next stop", "\tx\n", 9},
+		{"tab after text", "abc\tx\n", 9},
+		{"tab at a stop", "abcdefgh\tx\n", 17},
+		{"crlf line endings", "abc\r\nab\r\n", 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := MaxLineLength(strings.NewReader(tc.input))
+			if actual != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTabWidth(t *testing.T) {
+	testCases := []struct {
+		input    string
+		tabWidth int
+		chars    int
+		longest  int
+	}{
+		{"a\tb\n", 0, 4, 9},
+		{"a\tb\n", 4, 6, 5},
+		{"\tx\n\t\tx\n", 4, 16, 9},
+		{"abcd\tx", 4, 9, 9},
+		{"a b\n", 4, 4, 3},
+	}
+
+	for _, tc := range testCases {
+		if actual := CountCharsTabs(strings.NewReader(tc.input), tc.tabWidth); actual != tc.chars {
+			t.Errorf("CountCharsTabs(%q, %d): expected %d, got %d", tc.input, tc.tabWidth, tc.chars, actual)
+		}
+		if actual := MaxLineLengthTabs(strings.NewReader(tc.input), tc.tabWidth); actual != tc.longest {
+			t.Errorf("MaxLineLengthTabs(%q, %d): expected %d, got %d", tc.input, tc.tabWidth, tc.longest, actual)
+		}
+	}
+}
+
+func TestCapsStats(t *testing.T) {
+	testCases := []struct {
+		name                            string
+		input                           string
+		allCaps, capitalized, lowercase int
+	}{
+		{"empty input", "", 0, 0, 0},
+		{"each kind", "NASA launched Apollo eleven", 1, 1, 2},
+		{"punctuation ignored", "\"STOP!\" she said, Firmly.", 1, 1, 2},
+		{"single capital letter", "I A a", 0, 2, 1},
+		{"mixed case", "iPhone McDonald", 0, 1, 1},
+		{"non-ASCII", "ÉCOLE Élan été ΑΘΗΝΑ Αθήνα", 2, 2, 1},
+		{"no cased letters", "42 -- 東京 ½", 0, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			allCaps, capitalized, lowercase := CapsStats(strings.NewReader(tc.input), FrequencyOptions{})
+			if allCaps != tc.allCaps || capitalized != tc.capitalized || lowercase != tc.lowercase {
+				t.Errorf("Expected %d %d %d, got %d %d %d", tc.allCaps, tc.capitalized, tc.lowercase, allCaps, capitalized, lowercase)
+			}
+		})
+	}
+}
+
+func TestLineLengths(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		chars, lines int
+	}{
+		{"empty input", "", 0, 0},
+		{"trailing newline", "ab\ncdef\n", 6, 2},
+		{"no trailing newline", "ab\ncd", 4, 2},
+		{"blank lines", "a\n\n\n", 1, 3},
+		{"runes not bytes", "héllo\n", 5, 1},
+		{"crlf line endings", "abc\r\nde\r\n", 5, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chars, lines := LineLengths(strings.NewReader(tc.input))
+			if chars != tc.chars || lines != tc.lines {
+				t.Errorf("Expected %d chars in %d lines, got %d in %d", tc.chars, tc.lines, chars, lines)
+			}
+			if expected := CountLines(strings.NewReader(tc.input)); lines != expected {
+				t.Errorf("Expected %d lines like CountLines, got %d", expected, lines)
+			}
+		})
+	}
+}
+
+func TestCountSentences(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{"empty input", "", 0},
+		{"whitespace only", "  \n\t ", 0},
+		{"single sentence", "Hello there.", 1},
+		{"mixed terminators", "Hello. How are you? Great!", 3},
+		{"collapsed runs", "Wait... What?! Really.", 3},
+		{"unterminated final clause", "First sentence. And a trailing clause", 2},
+		{"trailing whitespace", "One. Two.   \n\n", 2},
+		{"terminators only", "...", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := CountSentences(strings.NewReader(tc.input))
+			if actual != tc.expected {
+				t.Errorf("Expected %d, got %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// TestSummary tests that the single pass agrees with each separate counter
+func TestSummary(t *testing.T) {
+	texts := []string{
+		"",
+		"\n",
+		"Hello there. How are you?\n\nFine café!\nok",
+		"one\ttwo\r\nthree...\n\n\n  \nfour?! five\n",
+		"\xff\xfe bad bytes",
+		"don't stop-me now 42x",
+		"日本語の文章。Mixed 中文text",
+	}
+	modes := []FrequencyOptions{{}, {WordMode: WordsAlpha}, {WordMode: WordsAlnum}, {CJK: true}}
+	for _, text := range texts {
+		for _, opts := range modes {
+			expected := SummaryStats{
+				Lines:      CountLines(strings.NewReader(text)),
+				Words:      CountWordsSplit(strings.NewReader(text), opts.splitFunc()),
+				Chars:      CountChars(strings.NewReader(text)),
+				Bytes:      CountBytes(strings.NewReader(text)),
+				Sentences:  CountSentences(strings.NewReader(text)),
+				Paragraphs: Density(strings.NewReader(text), opts).Paragraphs,
+			}
+			if actual := Summary(strings.NewReader(text), opts); actual != expected {
+				t.Errorf("Summary(%q, %+v): expected %+v, got %+v", text, opts, expected, actual)
+			}
+			if words := Density(strings.NewReader(text), opts).Words; words != expected.Words {
+				t.Errorf("Density(%q, %+v): expected %d words, got %d", text, opts, expected.Words, words)
+			}
+		}
+	}
+}
+
+func TestCountUniqueWords(t *testing.T) {
+	// "The", "the," and "THE" normalize to the same word
+	b := bytes.NewBufferString("The cat saw the, dog. THE end!")
+
+	expected := 5
+	actual := CountUniqueWords(b, FrequencyOptions{})
+
+	if actual != expected {
+		t.Errorf("Expected %d, got %d.\n", expected, actual)
+	}
+
+	if actual := CountUniqueWords(strings.NewReader(""), FrequencyOptions{}); actual != 0 {
+		t.Errorf("Expected 0 for empty input, got %d", actual)
+	}
+
+	// Words split on letters make "don't" into "don" and "t"
+	if actual := CountUniqueWords(strings.NewReader("don't don t"), FrequencyOptions{WordMode: WordsAlpha}); actual != 2 {
+		t.Errorf("Expected 2 for alpha words, got %d", actual)
+	}
+}
+
+func TestWordFrequencies(t *testing.T) {
+	testData := "the quick brown fox jumps over the lazy dog. The fox is quick and brown."
+	r := strings.NewReader(testData)
+
+	// Test with sort by count
+	frequencies, err := WordFrequencies(r, SortCount, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+
+	if len(frequencies) == 0 {
+		t.Fatal("Expected at least one word in frequency analysis")
+	}
+
+	if strings.ToLower(frequencies[0].Word) != "the" {
+		t.Errorf("Expected most frequent word to be 'the', got %q", frequencies[0].Word)
+	}
+
+	if frequencies[0].Count != 3 {
+		t.Errorf("Expected count for 'the' to be 3, got %d", frequencies[0].Count)
+	}
+
+	// Test alphabetical sorting
+	r = strings.NewReader(testData)
+	frequencies, err = WordFrequencies(r, SortAlpha, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+
+	// Check that results are alphabetically sorted
+	for i := 1; i < len(frequencies); i++ {
+		if frequencies[i-1].Word > frequencies[i].Word {
+			t.Errorf("Words not sorted alphabetically: %q should come after %q",
+				frequencies[i-1].Word, frequencies[i].Word)
+		}
+	}
+
+	// Test with limit
+	r = strings.NewReader(testData)
+	limit := 3
+	frequencies, err = WordFrequencies(r, SortCount, limit, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+
+	if len(frequencies) != limit {
+		t.Errorf("Expected %d words with limit, got %d", limit, len(frequencies))
+	}
+}
+
+func TestNgramFrequencies(t *testing.T) {
+	testData := "The cat sat. The cat ran. A dog sat."
+
+	// Bigrams sorted by count
+	frequencies, err := NgramFrequencies(strings.NewReader(testData), 2, SortCount, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze n-gram frequency: %v", err)
+	}
+	if len(frequencies) == 0 {
+		t.Fatal("Expected at least one bigram")
+	}
+	if frequencies[0].Word != "the cat" || frequencies[0].Count != 2 {
+		t.Errorf("Expected most frequent bigram to be 'the cat' (2), got %q (%d)", frequencies[0].Word, frequencies[0].Count)
+	}
+
+	// An n of 1 should match single-word frequency
+	single, err := NgramFrequencies(strings.NewReader(testData), 1, SortCount, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze n-gram frequency: %v", err)
+	}
+	words, err := WordFrequencies(strings.NewReader(testData), SortCount, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+	if fmt.Sprint(single) != fmt.Sprint(words) {
+		t.Errorf("Expected n=1 to match word frequency, got %v vs %v", single, words)
+	}
+
+	// Text shorter than n words yields no results without error
+	short, err := NgramFrequencies(strings.NewReader("only two"), 3, SortCount, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error for short text: %v", err)
+	}
+	if len(short) != 0 {
+		t.Errorf("Expected no trigrams for two-word text, got %v", short)
+	}
+}
+
+func TestStopwordFiltering(t *testing.T) {
+	testData := "The cat and the dog. The cat of the house."
+
+	// Without filtering the most frequent word is a stopword
+	frequencies, err := WordFrequencies(strings.NewReader(testData), SortCount, 0, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+	if frequencies[0].Word != "the" {
+		t.Errorf("Expected 'the' to be most frequent without filtering, got %q", frequencies[0].Word)
+	}
+
+	// With filtering, Stopwords are skipped entirely
+	frequencies, err = WordFrequencies(strings.NewReader(testData), SortCount, 0, FrequencyOptions{FilterStopwords: true})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+	for _, wf := range frequencies {
+		if Stopwords[wf.Word] {
+			t.Errorf("Expected stopword %q to be filtered", wf.Word)
+		}
+	}
+	if frequencies[0].Word != "cat" || frequencies[0].Count != 2 {
+		t.Errorf("Expected 'cat' (2) to be most frequent, got %q (%d)", frequencies[0].Word, frequencies[0].Count)
+	}
+
+	// Non-English text passes through unchanged
+	frequencies, err = WordFrequencies(strings.NewReader("le chat et le chien"), SortCount, 0, FrequencyOptions{FilterStopwords: true})
+	if err != nil {
+		t.Fatalf("Failed to analyze word frequency: %v", err)
+	}
+	if len(frequencies) != 4 {
+		t.Errorf("Expected 4 distinct French words, got %v", frequencies)
+	}
+}
+
+func TestMinCount(t *testing.T) {
+	testData := "a a a a b b b c c d"
+
+	testCases := []struct {
+		name     string
+		minCount int
+		limit    int
+		expected []string
+	}{
+		{"no threshold", 0, 10, []string{"a", "b", "c", "d"}},
+		{"threshold drops rare words", 2, 10, []string{"a", "b", "c"}},
+		{"threshold applied before limit", 2, 2, []string{"a", "b"}},
+		{"threshold above every count", 5, 10, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			frequencies, err := WordFrequencies(strings.NewReader(testData), SortCount, tc.limit, FrequencyOptions{MinCount: tc.minCount})
+			if err != nil {
+				t.Fatalf("Failed to analyze word frequency: %v", err)
+			}
+			var words []string
+			for _, wf := range frequencies {
+				words = append(words, wf.Word)
+			}
+			if fmt.Sprint(words) != fmt.Sprint(tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, words)
+			}
+		})
+	}
+}
+
+// TestDetectLanguage tests the language detection function
+func TestDetectLanguage(t *testing.T) {
+	// Add a special test case for empty tag path - we'll test with a very unusual input
+	// that is likely to confuse the language detector
+	t.Run("weird language case", func(t *testing.T) {
+		// Create a reader with unusual input that might trigger edge cases
+		// Just a bunch of symbols that shouldn't be identifiable as any language
+		r := strings.NewReader("∞≠≈∫∂∑∏√∛∜⋯♠♥♦♣♤♡♢♧⚀⚁⚂⚃⚄⚅")
+
+		// Call the function
+		tag, name, _, err := DetectLanguage(r, LanguageOptions{})
+
+		// We don't really care what language it detects,
+		// we just want to make sure it doesn't error
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		// Just verify we got something back
+		if tag == "" {
+			t.Error("Expected a non-empty tag")
+		}
+
+		if name == "" {
+			t.Error("Expected a non-empty name")
+		}
+	})
+
+	tests := []struct {
+		name      string
+		input     string
+		expectTag string
+		expectErr bool
+	}{
+		{
+			name:      "English text",
+			input:     "This is English text for testing purposes.",
+			expectTag: "en",
+			expectErr: false,
+		},
+		{
+			name:      "Spanish text",
+			input:     "El zorro marrón rápido salta sobre el perro perezoso.",
+			expectTag: "es",
+			expectErr: false,
+		},
+		{
+			name:      "French text",
+			input:     "Le renard brun rapide saute par-dessus le chien paresseux.",
+			expectTag: "fr",
+			expectErr: false,
+		},
+		{
+			name:      "Portuguese text",
+			input:     "A raposa marrom rápida pula sobre o cão preguiçoso.",
+			expectTag: "pt",
+			expectErr: false,
+		},
+		{
+			name:      "Chinese text",
+			input:     "快速的棕色狐狸跳过懒惰的狗。",
+			expectTag: "zh",
+			expectErr: false,
+		},
+		{
+			name:      "Empty text",
+			input:     "",
+			expectTag: "und",
+			expectErr: false,
+		},
+		{
+			name:      "Very short text",
+			input:     "hi",
+			expectTag: "en", // may be detected as other languages, but we're just testing the flow
+			expectErr: false,
+		},
+		{
+			name:      "Reader error simulation",
+			input:     "This text will be read via a custom reader that will error",
+			expectTag: "",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var r io.Reader
+
+			if tc.name == "Reader error simulation" {
+				// Create a custom reader that will error
+				r = &errorReader{err: fmt.Errorf("simulated read error")}
+			} else {
+				r = strings.NewReader(tc.input)
+			}
+
+			tag, name, _, err := DetectLanguage(r, LanguageOptions{})
+
+			if tc.expectErr && err == nil {
+				t.Error("Expected an error but got none")
+			}
+
+			if !tc.expectErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			// Skip further checks if we expected an error
+			if tc.expectErr {
+				return
+			}
+
+			// For empty or very short texts, we're just testing that the function handles them gracefully
+			if tc.input == "" || len(tc.input) < 5 {
+				// Just check that the function returns something reasonable
+				if tag == "" {
+					t.Error("Expected a language tag but got empty string")
+				}
+			} else if !tc.expectErr && !strings.HasPrefix(tag, tc.expectTag) {
+				t.Errorf("Expected language tag starting with %q, got %q", tc.expectTag, tag)
+			}
+
+			if !tc.expectErr && tag != "und" && name == "" {
+				t.Error("Expected a non-empty language name")
+			}
+
+			// Test for special cases where we add region codes, but only for longer texts
+			// Skip the very short text test since language detection can be unreliable
+			if tc.name != "Very short text" && tc.input != "" && len(tc.input) > 10 {
+				switch tc.expectTag {
+				case "en":
+					if tag != "en-US" {
+						t.Errorf("Expected English to be tagged as en-US, got %s", tag)
+					}
+					if name != "English (US)" {
+						t.Errorf("Expected English name to be 'English (US)', got %s", name)
+					}
+				case "es":
+					if tag != "es-ES" {
+						t.Errorf("Expected Spanish to be tagged as es-ES, got %s", tag)
+					}
+					if name != "Spanish (Spain)" {
+						t.Errorf("Expected Spanish name to be 'Spanish (Spain)', got %s", name)
+					}
+				case "pt":
+					if tag != "pt-BR" {
+						t.Errorf("Expected Portuguese to be tagged as pt-BR, got %s", tag)
+					}
+					if name != "Portuguese (Brazil)" {
+						t.Errorf("Expected Portuguese name to be 'Portuguese (Brazil)', got %s", name)
+					}
+				case "zh":
+					if tag != "zh-CN" {
+						t.Errorf("Expected Chinese to be tagged as zh-CN, got %s", tag)
+					}
+					if name != "Chinese (Simplified)" {
+						t.Errorf("Expected Chinese name to be 'Chinese (Simplified)', got %s", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+// errorReader is a custom reader that always returns an error
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read(p []byte) (n int, err error) {
+	return 0, r.err
+}
+
+// TestLanguageMinWords tests that short inputs fall back to und below --lang-min-words
+func TestLanguageMinWords(t *testing.T) {
+	// A 2-word input should be undetermined when at least 5 words are required
+	tag, name, _, err := DetectLanguage(strings.NewReader("hello world"), LanguageOptions{MinWords: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag != "und" || name != "Unknown" {
+		t.Errorf("Expected und/Unknown for short input, got %s/%s", tag, name)
+	}
+
+	// A longer input should still be detected normally
+	tag, _, _, err = DetectLanguage(strings.NewReader("This is a longer piece of English text for testing purposes."), LanguageOptions{MinWords: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag != "en-US" {
+		t.Errorf("Expected en-US for longer input, got %s", tag)
+	}
+}
+
+// TestLanguageMinConfidence tests that unsure guesses fall back to und below --lang-min-confidence
+func TestLanguageMinConfidence(t *testing.T) {
+	// "hello world" is too little to go on, so the detector is barely sure of it
+	tag, name, confidence, err := DetectLanguage(strings.NewReader("hello world"), LanguageOptions{MinConfidence: 0.5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag != "und" || name != "Unknown" || confidence != 0 {
+		t.Errorf("Expected und/Unknown with no confidence for an unsure guess, got %s/%s (%.2f)", tag, name, confidence)
+	}
+
+	// A confident guess is kept
+	tag, _, confidence, err = DetectLanguage(strings.NewReader("This is a longer piece of English text for testing purposes."), LanguageOptions{MinConfidence: 0.5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag != "en-US" || confidence < 0.5 {
+		t.Errorf("Expected en-US with confidence of at least 0.5, got %s (%.2f)", tag, confidence)
+	}
+}
+
+// TestLanguageConfidence tests that the confidence reflects how much text there is to go on
+func TestLanguageConfidence(t *testing.T) {
+	_, _, long, err := DetectLanguage(strings.NewReader("The quick brown fox jumps over the lazy dog. This is a longer piece of English text for testing purposes."), LanguageOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if long <= 0.8 || long > 1 {
+		t.Errorf("Expected a reliable confidence for long English text, got %.2f", long)
+	}
+
+	_, _, short, err := DetectLanguage(strings.NewReader("hi there"), LanguageOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if short >= long {
+		t.Errorf("Expected short text (%.2f) to be less confident than long text (%.2f)", short, long)
+	}
+
+	// Undetermined input has no confidence at all
+	_, _, none, err := DetectLanguage(strings.NewReader(""), LanguageOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if none != 0 {
+		t.Errorf("Expected 0 confidence for empty input, got %.2f", none)
+	}
+}
+
+// TestLanguageHints tests that hints restrict detection to the hinted languages
+func TestLanguageHints(t *testing.T) {
+	// Too short for the detector to get right on its own
+	tag, _, _, err := DetectLanguage(strings.NewReader("ciao"), LanguageOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag == "it" {
+		t.Fatalf("Expected \"ciao\" to be misdetected without hints, got %s", tag)
+	}
+
+	testCases := []struct {
+		name     string
+		hints    []string
+		expected string
+	}{
+		{"hinted", []string{"en", "it"}, "it"},
+		{"ISO 639-3 codes", []string{"eng", "ita"}, "it"},
+		{"unknown codes are skipped", []string{"xx", "it", "en"}, "it"},
+		{"only unknown codes", []string{"xx"}, tag},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, _, _, err := DetectLanguage(strings.NewReader("ciao"), LanguageOptions{Hints: tc.hints})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Hints %v: expected %s, got %s", tc.hints, tc.expected, actual)
+			}
+		})
+	}
+
+	// Candidates only come from the hinted languages
+	candidates, err := DetectLanguageCandidates(strings.NewReader("the cat sat"), 5, LanguageOptions{Hints: []string{"fr", "en"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Tag != "en-US" || candidates[1].Tag != "fr" {
+		t.Errorf("Expected en-US then fr, got %+v", candidates)
+	}
+
+	for code, expected := range map[string]bool{"en": true, "EN": true, "eng": true, "xx": false, "": false} {
+		if actual := IsLanguageCode(code); actual != expected {
+			t.Errorf("IsLanguageCode(%q): expected %v, got %v", code, expected, actual)
+		}
+	}
+}
+
+func TestDetectLanguageCandidates(t *testing.T) {
+	text := "Der schnelle braune Fuchs springt über den faulen Hund und das ist gut so"
+	candidates, err := DetectLanguageCandidates(strings.NewReader(text), 3, LanguageOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("Expected 3 candidates, got %+v", candidates)
+	}
+	if candidates[0].Tag != "de" || candidates[0].Name != "German" {
+		t.Errorf("Expected German to rank first, got %+v", candidates[0])
+	}
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if seen[c.Tag] {
+			t.Errorf("Candidate %s listed twice in %+v", c.Tag, candidates)
+		}
+		seen[c.Tag] = true
+	}
+
+	// The top candidate agrees with DetectLanguage
+	tag, _, _, _ := DetectLanguage(strings.NewReader(text), LanguageOptions{})
+	if tag != candidates[0].Tag {
+		t.Errorf("Expected top candidate %s to match DetectLanguage %s", candidates[0].Tag, tag)
+	}
+
+	// Single-language scripts can't offer alternatives
+	candidates, err = DetectLanguageCandidates(strings.NewReader("你好世界"), 3, LanguageOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Tag != "zh-CN" {
+		t.Errorf("Expected only zh-CN, got %+v", candidates)
+	}
+
+	// Empty and undetectable input yield a single undetermined candidate
+	for _, input := range []string{"", "12345 67890"} {
+		candidates, err = DetectLanguageCandidates(strings.NewReader(input), 3, LanguageOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", input, err)
+		}
+		expected := []LanguageCandidate{{Tag: "und", Name: "Unknown"}}
+		if fmt.Sprint(candidates) != fmt.Sprint(expected) {
+			t.Errorf("Expected %v for %q, got %v", expected, input, candidates)
+		}
+	}
+
+	// Read errors are passed through
+	if _, err := DetectLanguageCandidates(&errorReader{err: io.ErrUnexpectedEOF}, 3, LanguageOptions{}); err == nil {
+		t.Error("Expected an error from a failing reader")
+	}
+}
+
+func TestLanguageRegions(t *testing.T) {
+	english := "This is a longer piece of English text for testing purposes."
+	portuguese := "O rato roeu a roupa do rei de Roma e a rainha ficou muito zangada com isso."
+
+	testCases := []struct {
+		name         string
+		text         string
+		regions      map[string]string
+		expectedTag  string
+		expectedName string
+	}{
+		{"default region", english, nil, "en-US", "English (US)"},
+		{"overridden region", english, map[string]string{"en": "en-GB"}, "en-GB", "English (GB)"},
+		{"region removed", english, map[string]string{"en": "en"}, "en", "English"},
+		{"other overrides ignored", portuguese, map[string]string{"en": "en-GB"}, "pt-BR", "Portuguese (Brazil)"},
+		{"second override", portuguese, map[string]string{"en": "en-GB", "pt": "pt-PT"}, "pt-PT", "Portuguese (PT)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tag, name, _, err := DetectLanguage(strings.NewReader(tc.text), LanguageOptions{Regions: tc.regions})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tag != tc.expectedTag || name != tc.expectedName {
+				t.Errorf("Expected %s/%s, got %s/%s", tc.expectedTag, tc.expectedName, tag, name)
+			}
+		})
+	}
+}
+
+func TestDetectScript(t *testing.T) {
+	testCases := map[string]string{
+		"The quick brown fox": "Latin",
+		"Привет мир как дела": "Cyrillic",
+		"你好世界":                "Han",
+		"こんにちは":               "Hiragana/Katakana",
+		"مرحبا بالعالم":       "Arabic",
+		"12345 67890":         "Unknown",
+		"":                    "Unknown",
+	}
+
+	for text, expected := range testCases {
+		script, err := DetectScript(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", text, err)
+		}
+		if script != expected {
+			t.Errorf("DetectScript(%q): expected %s, got %s", text, expected, script)
+		}
+	}
+}
+
+func TestCaseSensitiveFrequencies(t *testing.T) {
+	text := "US us Us, us. The the"
+
+	frequencies, err := WordFrequencies(strings.NewReader(text), SortCount, 10, FrequencyOptions{CaseSensitive: true})
+	if err != nil {
+		t.Fatalf("WordFrequencies returned error: %v", err)
+	}
+	expected := []WordFrequency{{"us", 2}, {"The", 1}, {"US", 1}, {"Us", 1}, {"the", 1}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+
+	// Stopwords are still recognized in any case
+	frequencies, err = WordFrequencies(strings.NewReader(text), SortCount, 10, FrequencyOptions{CaseSensitive: true, FilterStopwords: true})
+	if err != nil {
+		t.Fatalf("WordFrequencies returned error: %v", err)
+	}
+	expected = []WordFrequency{{"us", 2}, {"US", 1}, {"Us", 1}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+}
+
+func TestTrimOptions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		word     string
+		opts     FrequencyOptions
+		expected string
+	}{
+		{"default trims punctuation", "(word).", FrequencyOptions{}, "word"},
+		{"default keeps hashtags", "#golang,", FrequencyOptions{}, "#golang"},
+		{"custom set", "—#golang—", FrequencyOptions{TrimChars: "—#"}, "golang"},
+		{"custom set replaces default", "word.", FrequencyOptions{TrimChars: "#"}, "word."},
+		{"no trim", "word.", FrequencyOptions{NoTrim: true}, "word."},
+		{"no trim still lowercases", "Word.", FrequencyOptions{NoTrim: true, TrimChars: "."}, "word."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := NormalizeWord(tc.word, tc.opts); actual != tc.expected {
+				t.Errorf("NormalizeWord(%q): expected %q, got %q", tc.word, tc.expected, actual)
+			}
+		})
+	}
+
+	// Without trimming, "word." and "word" are distinct tokens
+	counts, err := WordCounts(strings.NewReader("word word. word"), FrequencyOptions{NoTrim: true})
+	if err != nil {
+		t.Fatalf("WordCounts returned error: %v", err)
+	}
+	if counts["word"] != 2 || counts["word."] != 1 {
+		t.Errorf("Expected word=2 and word.=1, got %v", counts)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		opts     FrequencyOptions
+		expected string
+	}{
+		{"document order", "The cat, the \"Hat\".\nA  dog!", FrequencyOptions{}, "the cat the hat a dog"},
+		{"skips empty tokens", "one -- ... two", FrequencyOptions{}, "one -- two"},
+		{"case sensitive", "The Cat", FrequencyOptions{CaseSensitive: true}, "The Cat"},
+		{"custom trim", "#go# (go)", FrequencyOptions{TrimChars: "#"}, "go (go)"},
+		{"stopwords", "the cat and the hat", FrequencyOptions{FilterStopwords: true}, "cat hat"},
+		{"empty", "", FrequencyOptions{}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var tokens []string
+			err := Tokens(strings.NewReader(tc.text), tc.opts, func(word string) {
+				tokens = append(tokens, word)
+			})
+			if err != nil {
+				t.Fatalf("Tokens returned error: %v", err)
+			}
+			if actual := strings.Join(tokens, " "); actual != tc.expected {
+				t.Errorf("Tokens(%q): expected %q, got %q", tc.text, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWordLengthHistogram(t *testing.T) {
+	histogram := WordLengthHistogram(strings.NewReader("A bb, (bb) café ... supercalifragilisticexpialidocious"), FrequencyOptions{})
+	expected := map[int]int{1: 1, 2: 2, 4: 1, 34: 1}
+	if fmt.Sprint(histogram) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, histogram)
+	}
+
+	if histogram := WordLengthHistogram(strings.NewReader(""), FrequencyOptions{}); len(histogram) != 0 {
+		t.Errorf("Expected an empty histogram, got %v", histogram)
+	}
+}
+
+// TestWordReportsSplit tests that the word reports split words as the options
+// say, so they agree with the word count
+func TestWordReportsSplit(t *testing.T) {
+	alpha := FrequencyOptions{WordMode: WordsAlpha}
+
+	histogram := WordLengthHistogram(strings.NewReader("don't stop"), alpha)
+	if expected := map[int]int{1: 1, 3: 1, 4: 1}; fmt.Sprint(histogram) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, histogram)
+	}
+	histogram = WordLengthHistogram(strings.NewReader("東京 abc"), FrequencyOptions{CJK: true})
+	if expected := map[int]int{1: 2, 3: 1}; fmt.Sprint(histogram) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v with CJK, got %v", expected, histogram)
+	}
+
+	avg, longest, shortest := WordStats(strings.NewReader("don't stop"), alpha)
+	if math.Abs(avg-8.0/3) > 1e-9 || longest != "stop" || shortest != "t" {
+		t.Errorf("Expected (2.67, stop, t), got (%v, %q, %q)", avg, longest, shortest)
+	}
+
+	allCaps, capitalized, lower := CapsStats(strings.NewReader("NASA's USA-made"), alpha)
+	if allCaps != 2 || capitalized != 0 || lower != 2 {
+		t.Errorf("Expected (2, 0, 2), got (%d, %d, %d)", allCaps, capitalized, lower)
+	}
+}
+
+func TestAffixCounts(t *testing.T) {
+	text := "Running jumped, walking; Talked up naïve"
+	testCases := []struct {
+		n        int
+		suffix   bool
+		expected string
+	}{
+		{3, true, "map[ing:2 ked:1 ped:1 ïve:1]"},
+		{2, false, "map[ju:1 na:1 ru:1 ta:1 up:1 wa:1]"},
+		{4, false, "map[jump:1 naïv:1 runn:1 talk:1 walk:1]"},
+		{8, true, "map[]"},
+	}
+	for _, tc := range testCases {
+		counts, err := AffixCounts(strings.NewReader(text), tc.n, tc.suffix, FrequencyOptions{})
+		if err != nil {
+			t.Fatalf("AffixCounts returned error: %v", err)
+		}
+		if fmt.Sprint(counts) != tc.expected {
+			t.Errorf("AffixCounts(%d, %v): expected %s, got %v", tc.n, tc.suffix, tc.expected, counts)
+		}
+	}
+}
+
+func TestWordPositions(t *testing.T) {
+	counts, firstSeen, err := WordPositions(strings.NewReader("The cat saw the dog. A dog!"), FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("WordPositions returned error: %v", err)
+	}
+	expectedCounts := map[string]int{"the": 2, "cat": 1, "saw": 1, "dog": 2, "a": 1}
+	if fmt.Sprint(counts) != fmt.Sprint(expectedCounts) {
+		t.Errorf("Expected counts %v, got %v", expectedCounts, counts)
+	}
+	expectedFirst := map[string]int{"the": 1, "cat": 2, "saw": 3, "dog": 5, "a": 6}
+	if fmt.Sprint(firstSeen) != fmt.Sprint(expectedFirst) {
+		t.Errorf("Expected first positions %v, got %v", expectedFirst, firstSeen)
+	}
+
+	// Filtered words don't take up a position
+	_, firstSeen, _ = WordPositions(strings.NewReader("the cat and the hat"), FrequencyOptions{FilterStopwords: true})
+	if expected := map[string]int{"cat": 1, "hat": 2}; fmt.Sprint(firstSeen) != fmt.Sprint(expected) {
+		t.Errorf("Expected first positions %v, got %v", expected, firstSeen)
+	}
+}
+
+func TestSortFrequenciesByLength(t *testing.T) {
+	counts := map[string]int{"ox": 5, "zebra": 1, "café": 2, "apple": 3, "a": 4}
+
+	// Longest first counting runes, so "café" is 4 long, with alphabetical ties
+	frequencies := SortFrequencies(counts, SortLength, 0, FrequencyOptions{})
+	expected := []WordFrequency{{"apple", 3}, {"zebra", 1}, {"café", 2}, {"ox", 5}, {"a", 4}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+
+	// The empty mode sorts alphabetically
+	frequencies = SortFrequencies(counts, "", 2, FrequencyOptions{})
+	expected = []WordFrequency{{"a", 4}, {"apple", 3}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+}
+
+func TestSortFrequenciesReverse(t *testing.T) {
+	counts := map[string]int{"ox": 5, "zebra": 1, "café": 2, "apple": 3, "a": 4}
+
+	// The limit keeps the bottom N once the order is reversed
+	frequencies := SortFrequencies(counts, SortCount, 2, FrequencyOptions{Reverse: true})
+	expected := []WordFrequency{{"zebra", 1}, {"café", 2}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+
+	frequencies = SortFrequencies(counts, SortLength, 2, FrequencyOptions{Reverse: true})
+	expected = []WordFrequency{{"a", 4}, {"ox", 5}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+
+	chars, err := CharFrequencies(strings.NewReader("abbccc"), SortCount, 1, FrequencyOptions{Reverse: true})
+	if err != nil {
+		t.Fatalf("CharFrequencies returned error: %v", err)
+	}
+	if len(chars) != 1 || chars[0] != (CharFrequency{'a', 1}) {
+		t.Errorf("Expected [{a 1}], got %v", chars)
+	}
+}
+
+// TestTopFrequencies tests that the heap used for a limit gives exactly what
+// sorting every word and truncating would, ties and all
+func TestTopFrequencies(t *testing.T) {
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		counts[fmt.Sprintf("%s%d", strings.Repeat("w", i%5+1), i)] = i % 7
+	}
+
+	for _, mode := range []SortMode{SortAlpha, SortCount, SortLength} {
+		for _, reverse := range []bool{false, true} {
+			for _, minCount := range []int{0, 3} {
+				opts := FrequencyOptions{Reverse: reverse, MinCount: minCount}
+				all := SortFrequencies(counts, mode, 0, opts)
+				for _, limit := range []int{1, 10, 57, len(all) - 1} {
+					actual := SortFrequencies(counts, mode, limit, opts)
+					expected := all[:limit]
+					if fmt.Sprint(actual) != fmt.Sprint(expected) {
+						t.Errorf("%s, reverse %v, min count %d, limit %d: expected %v, got %v", mode, reverse, minCount, limit, expected, actual)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCharFrequencies(t *testing.T) {
+	text := "abba c\tb\n"
+
+	frequencies, err := CharFrequencies(strings.NewReader(text), SortCount, 10, FrequencyOptions{})
+	if err != nil {
+		t.Fatalf("CharFrequencies returned error: %v", err)
+	}
+	expected := []CharFrequency{{'b', 3}, {'a', 2}, {'c', 1}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+
+	// Whitespace is counted only when asked for, and sorts by code point
+	frequencies, err = CharFrequencies(strings.NewReader(text), SortAlpha, 3, FrequencyOptions{IncludeWhitespace: true})
+	if err != nil {
+		t.Fatalf("CharFrequencies returned error: %v", err)
+	}
+	expected = []CharFrequency{{'\t', 1}, {'\n', 1}, {' ', 1}}
+	if fmt.Sprint(frequencies) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, frequencies)
+	}
+
+	// Multibyte runes are counted as single characters
+	frequencies, err = CharFrequencies(strings.NewReader("ééa"), SortCount, 10, FrequencyOptions{MinCount: 2})
+	if err != nil {
+		t.Fatalf("CharFrequencies returned error: %v", err)
+	}
+	if len(frequencies) != 1 || frequencies[0] != (CharFrequency{'é', 2}) {
+		t.Errorf("Expected [{é 2}], got %v", frequencies)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	testCases := []struct {
+		text  string
+		runes float64
+		bytes float64
+	}{
+		{"", 0, 0},
+		{"aaaa", 0, 0},
+		{"ab", 1, 1},
+		{"abcd", 2, 2},
+		{"aab", 0.9183, 0.9183},
+		// é is one rune but two bytes
+		{"éa", 1, math.Log2(3)},
+	}
+	for _, tc := range testCases {
+		if actual := ShannonEntropy(strings.NewReader(tc.text)); math.Abs(actual-tc.runes) > 0.0001 {
+			t.Errorf("ShannonEntropy(%q): expected %.4f, got %.4f", tc.text, tc.runes, actual)
+		}
+		if actual := ByteEntropy(strings.NewReader(tc.text)); math.Abs(actual-tc.bytes) > 0.0001 {
+			t.Errorf("ByteEntropy(%q): expected %.4f, got %.4f", tc.text, tc.bytes, actual)
+		}
+	}
+}
+
+func TestWordStats(t *testing.T) {
+	testCases := []struct {
+		name             string
+		input            string
+		expectedAvg      float64
+		expectedLongest  string
+		expectedShortest string
+	}{
+		{"Simple", "a bb ccc", 2, "ccc", "a"},
+		{"Punctuation trimmed", "Hello, (world)!", 5, "Hello", "Hello"},
+		{"Ties keep first", "cat dog ox ax", 2.5, "cat", "ox"},
+		{"Multibyte words", "café au", 3, "café", "au"},
+		{"Empty input", "", 0, "", ""},
+		{"Only punctuation", "... !!", 0, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			avg, longest, shortest := WordStats(strings.NewReader(tc.input), FrequencyOptions{})
+			if avg != tc.expectedAvg || longest != tc.expectedLongest || shortest != tc.expectedShortest {
+				t.Errorf("Expected (%v, %q, %q), got (%v, %q, %q)",
+					tc.expectedAvg, tc.expectedLongest, tc.expectedShortest, avg, longest, shortest)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package analyze
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// CodeStats holds statistics about code in a file or directory
+type CodeStats struct {
+	Total    int // Total lines
+	Code     int // Lines of code (non-blank, non-comment)
+	Comments int // Comment lines
+	Blank    int // Blank lines
+	Files    int // Number of files processed
+}
+
+// BlockComment is a pair of markers enclosing a comment that may span lines
+type BlockComment struct {
+	Open  string
+	Close string
+}
+
+// CommentStyle describes the comment and string syntax of a language. A
+// language can have several of each kind, e.g. SQL has both "--" and "/* */".
+type CommentStyle struct {
+	Line            []string       // Markers starting a comment that runs to the end of the line
+	Blocks          []BlockComment // Block comment markers, checked before the line markers
+	Quotes          []string       // String delimiters; strings end at the line end and allow backslash escapes
+	MultilineQuotes []string       // Delimiters of raw strings that may span lines, checked before Quotes
+}
+
+var (
+	cStyle            = CommentStyle{Line: []string{"//"}, Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`, "'"}}
+	cBackquoteStyle   = CommentStyle{Line: []string{"//"}, Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`, "'"}, MultilineQuotes: []string{"`"}}
+	cTripleQuoteStyle = CommentStyle{Line: []string{"//"}, Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`, "'"}, MultilineQuotes: []string{`"""`}}
+	hashStyle         = CommentStyle{Line: []string{"#"}, Quotes: []string{`"`, "'"}}
+	cssStyle          = CommentStyle{Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`, "'"}}
+	markupStyle       = CommentStyle{Blocks: []BlockComment{{"<!--", "-->"}}}
+)
+
+// commentStyles maps file extensions (without the leading dot) to their comment syntax
+var commentStyles = map[string]CommentStyle{
+	"go":    cBackquoteStyle,
+	"c":     cStyle,
+	"h":     cStyle,
+	"cpp":   cStyle,
+	"hpp":   cStyle,
+	"cs":    cStyle,
+	"java":  cStyle,
+	"js":    cBackquoteStyle,
+	"jsx":   cBackquoteStyle,
+	"ts":    cBackquoteStyle,
+	"tsx":   cBackquoteStyle,
+	"kt":    cTripleQuoteStyle,
+	"kts":   cTripleQuoteStyle,
+	"scala": cTripleQuoteStyle,
+	"swift": cTripleQuoteStyle,
+	// Rust's ' also starts lifetimes, so only " delimits strings
+	"rs":   {Line: []string{"//"}, Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`}},
+	"scss": cStyle,
+	"php":  {Line: []string{"//", "#"}, Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`, "'"}},
+	"css":  cssStyle,
+	"py":   {Line: []string{"#"}, Quotes: []string{`"`, "'"}, MultilineQuotes: []string{`"""`, "'''"}},
+	"sh":   hashStyle,
+	"bash": hashStyle,
+	"ex":   hashStyle,
+	"exs":  hashStyle,
+	"rb":   {Line: []string{"#"}, Blocks: []BlockComment{{"=begin", "=end"}}, Quotes: []string{`"`, "'"}},
+	"ps1":  {Line: []string{"#"}, Blocks: []BlockComment{{"<#", "#>"}}, Quotes: []string{`"`, "'"}},
+	"html": markupStyle,
+	"htm":  markupStyle,
+	"xml":  markupStyle,
+	"sql":  {Line: []string{"--"}, Blocks: []BlockComment{{"/*", "*/"}}, Quotes: []string{`"`, "'"}},
+	"lua":  {Line: []string{"--"}, Blocks: []BlockComment{{"--[[", "]]"}}, Quotes: []string{`"`, "'"}},
+}
+
+// commentSyntax returns the comment syntax for an extension (without the
+// leading dot), or no comment markers at all for extensions it doesn't know
+func commentSyntax(ext string) CommentStyle {
+	return commentStyles[strings.ToLower(ext)]
+}
+
+// CountCode counts lines of code, comments, and blank lines in source text.
+// The extension (without the leading dot, e.g. "go") selects the comment syntax.
+// A line counts as code when anything other than comments appears on it, so
+// "x := 1 // note" is code, and as a comment when it holds nothing but comments.
+// Comment markers inside string literals are ignored.
+func CountCode(r io.Reader, ext string) (CodeStats, error) {
+	stats := CodeStats{}
+	scanner := bufio.NewScanner(r)
+	cs := codeScanner{style: commentSyntax(ext)}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		stats.Total++
+
+		// Trimmed line for blank line detection
+		if strings.TrimSpace(line) == "" {
+			stats.Blank++
+			continue
+		}
+
+		// If not a comment or blank line, count as code
+		if cs.scanLine(line) {
+			stats.Code++
+		} else {
+			stats.Comments++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// codeScanner walks source one line at a time, carrying block comments and
+// multiline strings over from one line to the next
+type codeScanner struct {
+	style      CommentStyle
+	blockClose string // Marker ending the block comment we're inside, if any
+	rawQuote   string // Delimiter ending the multiline string we're inside, if any
+}
+
+// scanLine reports whether a non-blank line holds any code, as opposed to
+// nothing but comments
+func (cs *codeScanner) scanLine(line string) bool {
+	hasCode := false
+	quote := "" // Delimiter ending the single-line string we're inside, if any
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch {
+		case cs.blockClose != "":
+			if strings.HasPrefix(rest, cs.blockClose) {
+				i += len(cs.blockClose)
+				cs.blockClose = ""
+				continue
+			}
+			i++
+
+		case cs.rawQuote != "":
+			hasCode = true
+			if strings.HasPrefix(rest, cs.rawQuote) {
+				i += len(cs.rawQuote)
+				cs.rawQuote = ""
+				continue
+			}
+			i++
+
+		case quote != "":
+			if rest[0] == '\\' {
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(rest, quote) {
+				i += len(quote)
+				quote = ""
+				continue
+			}
+			i++
+
+		case rest[0] == ' ' || rest[0] == '\t':
+			i++
+
+		default:
+			// Blocks go first so Lua's "--[[" isn't taken for a "--" line comment
+			if open, close := cs.style.blockAt(rest); open != "" {
+				i += len(open)
+				cs.blockClose = close
+				continue
+			}
+			if cs.style.lineCommentAt(rest) {
+				return hasCode
+			}
+
+			hasCode = true
+			if q := prefixIn(rest, cs.style.MultilineQuotes); q != "" {
+				i += len(q)
+				cs.rawQuote = q
+				continue
+			}
+			if q := prefixIn(rest, cs.style.Quotes); q != "" {
+				i += len(q)
+				quote = q
+				continue
+			}
+			i++
+		}
+	}
+
+	return hasCode
+}
+
+// blockAt returns the markers of the block comment opening at the start of s, if any
+func (style CommentStyle) blockAt(s string) (open, close string) {
+	for _, block := range style.Blocks {
+		if strings.HasPrefix(s, block.Open) {
+			return block.Open, block.Close
+		}
+	}
+	return "", ""
+}
+
+// lineCommentAt reports whether a line comment starts at the start of s
+func (style CommentStyle) lineCommentAt(s string) bool {
+	return prefixIn(s, style.Line) != ""
+}
+
+// prefixIn returns the first of prefixes that s starts with, or "" if none do
+func prefixIn(s string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
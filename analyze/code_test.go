@@ -0,0 +1,100 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountCodeCommentStyles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ext      string
+		source   string
+		expected CodeStats
+	}{
+		{
+			name:     "HTML",
+			ext:      "html",
+			source:   "<!-- header -->\n<p>hi</p>\n<!--\n  multi\n-->\n\n<br>\n",
+			expected: CodeStats{Total: 7, Code: 2, Comments: 4, Blank: 1},
+		},
+		{
+			name:     "CSS",
+			ext:      "css",
+			source:   "/* reset */\nbody {\n  margin: 0; /* trailing */\n}\n/*\n * theme\n */\n",
+			expected: CodeStats{Total: 7, Code: 3, Comments: 4},
+		},
+		{
+			name:     "SQL",
+			ext:      "sql",
+			source:   "-- users\nSELECT *\n/* multi\n   line */\nFROM users;\n",
+			expected: CodeStats{Total: 5, Code: 2, Comments: 3},
+		},
+		{
+			name:     "Lua",
+			ext:      "lua",
+			source:   "--[[\nlong\n]]\n-- short\nprint(1)\n--[[ one line ]]\nx = 2\n",
+			expected: CodeStats{Total: 7, Code: 2, Comments: 5},
+		},
+		{
+			name:     "Go comment markers in strings",
+			ext:      "go",
+			source:   "url := \"http://example.com\"\nglob := \"/*.go\"\nr := '\\''\nesc := \"say \\\"/*\\\"\"\n// real comment\n",
+			expected: CodeStats{Total: 5, Code: 4, Comments: 1},
+		},
+		{
+			name:     "Go raw string spanning lines",
+			ext:      "go",
+			source:   "s := `\n/* not a comment\n// nor this`\n/* real */\n",
+			expected: CodeStats{Total: 4, Code: 3, Comments: 1},
+		},
+		{
+			name:     "Block comment opened after code",
+			ext:      "c",
+			source:   "int x = 1; /* starts here\nstill a comment\nends here */\nint y = 2;\n/* a */ int z; /* b */\n",
+			expected: CodeStats{Total: 5, Code: 3, Comments: 2},
+		},
+		{
+			name:     "Code after a block comment closes",
+			ext:      "java",
+			source:   "/*\n * doc\n */ int x;\n",
+			expected: CodeStats{Total: 3, Code: 1, Comments: 2},
+		},
+		{
+			name:     "Line comment hides a block opener",
+			ext:      "js",
+			source:   "let a = 1; // see /* below\nlet b = 2;\n",
+			expected: CodeStats{Total: 2, Code: 2},
+		},
+		{
+			name:     "Python hash in strings",
+			ext:      "py",
+			source:   "print(\"# not a comment\")\n# a comment\nx = '#'  # trailing\n",
+			expected: CodeStats{Total: 3, Code: 2, Comments: 1},
+		},
+		{
+			name:     "Uppercase extension",
+			ext:      "SQL",
+			source:   "-- note\nSELECT 1;\n",
+			expected: CodeStats{Total: 2, Code: 1, Comments: 1},
+		},
+		{
+			name:     "Unknown extension",
+			ext:      "txt",
+			source:   "# not a comment\n// nor this\n",
+			expected: CodeStats{Total: 2, Code: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, err := CountCode(strings.NewReader(tc.source), tc.ext)
+			if err != nil {
+				t.Fatalf("CountCode returned error: %v", err)
+			}
+			if stats != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package analyze
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// maxLanguageSampleWords caps how much text is read for language detection
+const maxLanguageSampleWords = 1000 // Reasonable limit to avoid memory issues with very large files
+
+// LanguageCandidate is one possible language for a text, ranked by DetectLanguageCandidates
+type LanguageCandidate struct {
+	Tag   string
+	Name  string
+	Score float64
+}
+
+// LanguageOptions controls how languages are detected and reported
+type LanguageOptions struct {
+	MinWords      int               // Report shorter inputs as undetermined
+	MinConfidence float64           // Report guesses with a lower confidence as undetermined
+	Regions       map[string]string // Override DefaultRegions, e.g. "en" to "en-GB"
+	Hints         []string          // Only consider these languages, e.g. "en", "es"
+}
+
+// IsLanguageCode reports whether code is an ISO 639-1 (e.g. "en") or 639-3
+// (e.g. "eng") code for a language the detector knows, so it can be a hint
+func IsLanguageCode(code string) bool {
+	_, ok := languageByCode(code)
+	return ok
+}
+
+// languageByCode looks up the language for an ISO 639-1 or 639-3 code
+func languageByCode(code string) (whatlanggo.Lang, bool) {
+	code = strings.ToLower(code)
+	if code == "" {
+		return -1, false
+	}
+	if lang := whatlanggo.CodeToLang(code); lang != -1 {
+		return lang, true
+	}
+	for lang := range whatlanggo.Langs {
+		if lang.Iso6391() == code {
+			return lang, true
+		}
+	}
+	return -1, false
+}
+
+// hintedLanguages returns the languages named by hints, skipping unknown
+// codes, or nil when there are none so every language is considered
+func hintedLanguages(hints []string) map[whatlanggo.Lang]bool {
+	var langs map[whatlanggo.Lang]bool
+	for _, code := range hints {
+		if lang, ok := languageByCode(code); ok {
+			if langs == nil {
+				langs = make(map[whatlanggo.Lang]bool)
+			}
+			langs[lang] = true
+		}
+	}
+	return langs
+}
+
+// DetectLanguage tries to detect the language of the text
+// and returns the language tag (e.g., en-US, es, fr), a human-readable name
+// and the detector's confidence between 0 and 1. Short or ambiguous text
+// gets a low confidence. Inputs with fewer than opts.MinWords words are
+// reported as undetermined with a confidence of 0.
+func DetectLanguage(r io.Reader, opts LanguageOptions) (tag, name string, confidence float64, err error) {
+	text, wordCount, err := languageSample(r)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	// If we didn't get any words (or too few to trust), we can't detect the language
+	if wordCount == 0 || wordCount < opts.MinWords {
+		return "und", "Unknown", 0, nil
+	}
+
+	// Use whatlanggo for accurate language detection, only choosing between
+	// the hinted languages if there are any
+	info := whatlanggo.DetectWithOptions(text, whatlanggo.Options{Whitelist: hintedLanguages(opts.Hints)})
+
+	langTag, langName := languageTag(info.Lang, opts.Regions)
+
+	// If the language is unknown, or the guess isn't confident enough,
+	// fall back to a sensible default
+	if langTag == "" || info.Confidence < opts.MinConfidence {
+		return "und", "Unknown", 0, nil
+	}
+
+	return langTag, langName, info.Confidence, nil
+}
+
+// DetectLanguageCandidates ranks up to n languages the text could be written in,
+// best first. Each candidate is found by detecting again with the earlier
+// candidates ruled out, and its score is the detector's confidence in that
+// choice over the languages still remaining. Empty, undetectable or too short
+// input yields a single "und"/"Unknown" candidate.
+func DetectLanguageCandidates(r io.Reader, n int, opts LanguageOptions) ([]LanguageCandidate, error) {
+	text, wordCount, err := languageSample(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []LanguageCandidate
+	if wordCount > 0 && wordCount >= opts.MinWords {
+		ruledOut := make(map[whatlanggo.Lang]bool)
+		hinted := hintedLanguages(opts.Hints)
+		for len(candidates) < n {
+			// whatlanggo ignores the blacklist when there's a whitelist, so
+			// rule candidates out of the hinted languages instead
+			options := whatlanggo.Options{Blacklist: ruledOut}
+			if hinted != nil {
+				if len(hinted) == 0 {
+					break
+				}
+				options = whatlanggo.Options{Whitelist: hinted}
+			}
+			info := whatlanggo.DetectWithOptions(text, options)
+
+			// Scripts with a single language keep returning it, so stop on a repeat
+			langTag, langName := languageTag(info.Lang, opts.Regions)
+			if langTag == "" || ruledOut[info.Lang] {
+				break
+			}
+
+			candidates = append(candidates, LanguageCandidate{Tag: langTag, Name: langName, Score: info.Confidence})
+			ruledOut[info.Lang] = true
+			delete(hinted, info.Lang)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return []LanguageCandidate{{Tag: "und", Name: "Unknown"}}, nil
+	}
+	return candidates, nil
+}
+
+// DetectScript reports the writing system the text is written in, such as
+// "Latin", "Cyrillic" or "Han", using the same sample as DetectLanguage.
+// Japanese kana is reported as "Hiragana/Katakana", and text with no
+// recognizable script as "Unknown".
+func DetectScript(r io.Reader) (string, error) {
+	text, _, err := languageSample(r)
+	if err != nil {
+		return "", err
+	}
+
+	script := whatlanggo.DetectScript(text)
+	if script == nil {
+		return "Unknown", nil
+	}
+	if name, ok := whatlanggo.Scripts[script]; ok {
+		return name, nil
+	}
+
+	// whatlanggo groups both kana scripts into one table it doesn't name
+	return "Hiragana/Katakana", nil
+}
+
+// languageSample reads up to maxLanguageSampleWords words from r,
+// joined by single spaces, and returns them with the word count
+func languageSample(r io.Reader) (string, int, error) {
+	// Read all the text (up to a reasonable limit)
+	// This gives better accuracy than just a small sample
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	var sample strings.Builder
+	wordCount := 0
+
+	for scanner.Scan() && wordCount < maxLanguageSampleWords {
+		if wordCount > 0 {
+			sample.WriteString(" ")
+		}
+		sample.WriteString(scanner.Text())
+		wordCount++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", 0, fmt.Errorf("error reading text: %w", err)
+	}
+
+	return sample.String(), wordCount, nil
+}
+
+// Region is the regional tag and name reported for a detected language
+type Region struct {
+	Tag  string
+	Name string
+}
+
+// DefaultRegions maps ISO 639-1 codes to the region reported for them when no
+// override is given. These are assumptions about where a language is most
+// likely from; the region itself is never detected from the text.
+var DefaultRegions = map[string]Region{
+	"en": {Tag: "en-US", Name: "English (US)"},
+	"es": {Tag: "es-ES", Name: "Spanish (Spain)"},
+	"pt": {Tag: "pt-BR", Name: "Portuguese (Brazil)"},
+	"zh": {Tag: "zh-CN", Name: "Chinese (Simplified)"},
+}
+
+// languageTag returns the tag and English name reported for a detected language,
+// or empty strings if the language is unknown. The regions map overrides
+// DefaultRegions, mapping ISO 639-1 codes to tags such as "en-GB".
+func languageTag(lang whatlanggo.Lang, regions map[string]string) (string, string) {
+	// Get the ISO language code
+	langTag := lang.Iso6391()
+	if langTag == "" {
+		return "", ""
+	}
+
+	// Get the English name of the language
+	langName := lang.String()
+
+	// Add the region from an override, named after the tag's region subtag
+	if tag, ok := regions[langTag]; ok {
+		if i := strings.IndexByte(tag, '-'); i >= 0 {
+			return tag, fmt.Sprintf("%s (%s)", langName, tag[i+1:])
+		}
+		return tag, langName
+	}
+
+	// Otherwise fall back to the assumed default region, if there is one
+	if region, ok := DefaultRegions[langTag]; ok {
+		return region.Tag, region.Name
+	}
+
+	return langTag, langName
+}
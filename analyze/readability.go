@@ -0,0 +1,189 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// FleschReadingEase scores how easy the text is to read on the Flesch scale,
+// where higher is easier and most prose falls between 0 and 100. Syllables are
+// estimated with CountSyllables, so the score is an approximation. Input with
+// no words or no sentences scores 0.
+func FleschReadingEase(r io.Reader) float64 {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0
+	}
+
+	sentences := CountSentences(bytes.NewReader(data))
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(bufio.ScanWords)
+
+	words, syllables := 0, 0
+	for scanner.Scan() {
+		word := strings.Trim(scanner.Text(), wordPunctuation)
+		if word == "" {
+			continue
+		}
+		words++
+		syllables += CountSyllables(word)
+	}
+
+	if words == 0 || sentences == 0 {
+		return 0
+	}
+
+	return 206.835 -
+		1.015*float64(words)/float64(sentences) -
+		84.6*float64(syllables)/float64(words)
+}
+
+// CountSyllables estimates the syllables in an English word by counting
+// groups of consecutive vowels, discounting a silent trailing "e".
+// Every word counts as at least one syllable.
+func CountSyllables(word string) int {
+	word = strings.ToLower(word)
+
+	count := 0
+	inVowelGroup := false
+	for _, ch := range word {
+		isVowel := strings.ContainsRune("aeiouy", ch)
+		if isVowel && !inVowelGroup {
+			count++
+		}
+		inVowelGroup = isVowel
+	}
+
+	// A final "e" is usually silent ("make"), except in endings like "-le" ("table")
+	if count > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") {
+		runes := []rune(word)
+		if len(runes) > 1 && !strings.ContainsRune("aeiouy", runes[len(runes)-2]) {
+			count--
+		}
+	}
+
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// ReadabilityLabel describes a Flesch Reading Ease score in words
+func ReadabilityLabel(score float64) string {
+	switch {
+	case score >= 90:
+		return "Very easy"
+	case score >= 80:
+		return "Easy"
+	case score >= 70:
+		return "Fairly easy"
+	case score >= 60:
+		return "Standard"
+	case score >= 50:
+		return "Fairly difficult"
+	case score >= 30:
+		return "Difficult"
+	default:
+		return "Very difficult"
+	}
+}
+
+// DensityStats holds word, sentence and paragraph counts and the average
+// number of words in each sentence and paragraph
+type DensityStats struct {
+	Words             int
+	Sentences         int
+	Paragraphs        int
+	WordsPerSentence  float64 // 0 when there are no sentences
+	WordsPerParagraph float64 // 0 when there are no paragraphs
+}
+
+// Density counts words, sentences and paragraphs in a single pass. Words are
+// split as opts selects, sentences are counted like CountSentences, and
+// paragraphs are runs of non-blank lines separated by one or more blank lines.
+func Density(r io.Reader, opts FrequencyOptions) DensityStats {
+	reader := bufio.NewReader(r)
+	words := newWordStarts(opts)
+
+	var stats DensityStats
+	inSentence, inParagraph, lineBlank := false, false, true
+	for {
+		ch, _, err := reader.ReadRune()
+		if err != nil {
+			break
+		}
+
+		if ch == '\n' {
+			// A blank line ends the paragraph
+			if lineBlank {
+				inParagraph = false
+			}
+			lineBlank = true
+		}
+
+		if words.add(ch) {
+			stats.Words++
+		}
+		if unicode.IsSpace(ch) {
+			continue
+		}
+
+		lineBlank = false
+		if !inParagraph {
+			inParagraph = true
+			stats.Paragraphs++
+		}
+
+		// Only the first terminator after some content ends a sentence
+		if ch == '.' || ch == '!' || ch == '?' {
+			if inSentence {
+				stats.Sentences++
+				inSentence = false
+			}
+		} else {
+			inSentence = true
+		}
+	}
+
+	// Count a final clause that wasn't terminated
+	if inSentence {
+		stats.Sentences++
+	}
+
+	if stats.Sentences > 0 {
+		stats.WordsPerSentence = float64(stats.Words) / float64(stats.Sentences)
+	}
+	if stats.Paragraphs > 0 {
+		stats.WordsPerParagraph = float64(stats.Words) / float64(stats.Paragraphs)
+	}
+	return stats
+}
+
+// TTRStats holds the type-token ratio of a text with the counts behind it
+type TTRStats struct {
+	Types  int     // Distinct words
+	Tokens int     // All words
+	Ratio  float64 // Types divided by Tokens, 0 when there are no words
+}
+
+// TypeTokenRatio measures lexical diversity as the number of distinct words
+// divided by the number of words, normalizing words as WordCounts does
+func TypeTokenRatio(r io.Reader, opts FrequencyOptions) (TTRStats, error) {
+	counts, err := WordCounts(r, opts)
+	if err != nil {
+		return TTRStats{}, err
+	}
+
+	stats := TTRStats{Types: len(counts)}
+	for _, count := range counts {
+		stats.Tokens += count
+	}
+	if stats.Tokens > 0 {
+		stats.Ratio = float64(stats.Types) / float64(stats.Tokens)
+	}
+	return stats, nil
+}
@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTypeTokenRatio(t *testing.T) {
+	testCases := []struct {
+		text     string
+		opts     FrequencyOptions
+		expected TTRStats
+	}{
+		{"a b a b", FrequencyOptions{}, TTRStats{Types: 2, Tokens: 4, Ratio: 0.5}},
+		{"A a", FrequencyOptions{}, TTRStats{Types: 1, Tokens: 2, Ratio: 0.5}},
+		{"A a", FrequencyOptions{CaseSensitive: true}, TTRStats{Types: 2, Tokens: 2, Ratio: 1}},
+		{"-- ...", FrequencyOptions{}, TTRStats{Types: 1, Tokens: 1, Ratio: 1}},
+		{"", FrequencyOptions{}, TTRStats{}},
+	}
+	for _, tc := range testCases {
+		actual, err := TypeTokenRatio(strings.NewReader(tc.text), tc.opts)
+		if err != nil {
+			t.Fatalf("TypeTokenRatio returned error: %v", err)
+		}
+		if actual != tc.expected {
+			t.Errorf("TypeTokenRatio(%q): expected %+v, got %+v", tc.text, tc.expected, actual)
+		}
+	}
+}
+
+func TestDensity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected DensityStats
+	}{
+		{"empty input", "", DensityStats{}},
+		{"whitespace only", " \n\n\t\n", DensityStats{}},
+		{"one paragraph", "The cat sat. It slept!\nThen it woke", DensityStats{
+			Words: 8, Sentences: 3, Paragraphs: 1, WordsPerSentence: 8.0 / 3, WordsPerParagraph: 8,
+		}},
+		{"blank lines split paragraphs", "One two.\n\n  \nThree four five.\n\n\nSix.\n", DensityStats{
+			Words: 6, Sentences: 3, Paragraphs: 3, WordsPerSentence: 2, WordsPerParagraph: 2,
+		}},
+		{"no sentences", "... !!", DensityStats{Words: 2, Paragraphs: 1, WordsPerParagraph: 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := Density(strings.NewReader(tc.input), FrequencyOptions{})
+			if actual != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, actual)
+			}
+
+			// Words and sentences agree with the standalone counters
+			if words := CountWords(strings.NewReader(tc.input)); actual.Words != words {
+				t.Errorf("Expected %d words like CountWords, got %d", words, actual.Words)
+			}
+			if sentences := CountSentences(strings.NewReader(tc.input)); actual.Sentences != sentences {
+				t.Errorf("Expected %d sentences like CountSentences, got %d", sentences, actual.Sentences)
+			}
+		})
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	testCases := map[string]int{
+		"cat":         1,
+		"make":        1,
+		"table":       2,
+		"agree":       2,
+		"happy":       2,
+		"beautiful":   3,
+		"Readability": 5,
+		"rhythm":      1,
+		"hmm":         1,
+	}
+
+	for word, expected := range testCases {
+		if actual := CountSyllables(word); actual != expected {
+			t.Errorf("CountSyllables(%q): expected %d, got %d", word, expected, actual)
+		}
+	}
+}
+
+func TestFleschReadingEase(t *testing.T) {
+	// 2 sentences, 11 words, 12 syllables
+	score := FleschReadingEase(strings.NewReader("The cat sat on the mat. It was a sunny day."))
+	expected := 206.835 - 1.015*11/2 - 84.6*12/11
+	if math.Abs(score-expected) > 1e-9 {
+		t.Errorf("Expected %.4f, got %.4f", expected, score)
+	}
+
+	// Empty and punctuation-only input should score 0 rather than NaN
+	for _, input := range []string{"", "   ", "... !!"} {
+		if score := FleschReadingEase(strings.NewReader(input)); score != 0 {
+			t.Errorf("Expected 0 for %q, got %v", input, score)
+		}
+	}
+}
+
+func TestReadabilityLabel(t *testing.T) {
+	testCases := []struct {
+		score    float64
+		expected string
+	}{
+		{120, "Very easy"},
+		{85, "Easy"},
+		{72.5, "Fairly easy"},
+		{60, "Standard"},
+		{55, "Fairly difficult"},
+		{31, "Difficult"},
+		{-10, "Very difficult"},
+	}
+
+	for _, tc := range testCases {
+		if label := ReadabilityLabel(tc.score); label != tc.expected {
+			t.Errorf("ReadabilityLabel(%v): expected %q, got %q", tc.score, tc.expected, label)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// countWordOccurrences counts how often word appears in r, with both word
+// and text normalized as word frequency analysis normalizes them
+func countWordOccurrences(r io.Reader, word string, opts analyze.FrequencyOptions) (int, error) {
+	target := analyze.NormalizeWord(word, opts)
+	count := 0
+	err := analyze.Tokens(r, opts, func(w string) {
+		if w == target {
+			count++
+		}
+	})
+	return count, err
+}
+
+// runCountWord prints how many times cfg.CountWord appears in each input,
+// with a total for several files
+func runCountWord(stdin io.Reader, cfg *Config) error {
+	opts := frequencyOptions(cfg)
+	if analyze.NormalizeWord(cfg.CountWord, opts) == "" {
+		return fmt.Errorf("invalid --count-word %q: nothing left to count once normalized", cfg.CountWord)
+	}
+
+	total := 0
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		count, err := countWordOccurrences(r, cfg.CountWord, opts)
+		if err != nil {
+			return fmt.Errorf("failed to read words: %w", err)
+		}
+		printCount(cfg, count, path)
+		total += count
+		return nil
+	})
+	// With --keep-going the error only says some files failed, so the total
+	// still covers the rest
+	if err != nil && !cfg.KeepGoing {
+		return err
+	}
+
+	if len(cfg.Paths) > 1 {
+		printCount(cfg, total, "total")
+	}
+	return err
+}
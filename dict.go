@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// loadDictionary reads a newline-separated word list into a set, normalizing
+// each word the same way as the input it will be checked against
+func loadDictionary(path string, opts analyze.FrequencyOptions) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dictionary %s: %w", path, err)
+	}
+	defer file.Close()
+
+	// A stopword in the dictionary is still a known word
+	opts.FilterStopwords = false
+
+	dict := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if word := analyze.NormalizeWord(strings.TrimSpace(scanner.Text()), opts); word != "" {
+			dict[word] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dictionary %s: %w", path, err)
+	}
+	return dict, nil
+}
+
+// unknownWords counts the words in r that aren't in dict, normalized as
+// word frequency analysis normalizes them
+func unknownWords(r io.Reader, dict map[string]bool, cfg *Config) (map[string]int, error) {
+	counts, err := analyze.WordCounts(r, frequencyOptions(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check words: %w", err)
+	}
+	for word := range counts {
+		if dict[word] {
+			delete(counts, word)
+		}
+	}
+	return counts, nil
+}
+
+// runDictionaryCheck prints the words in each input that aren't in the
+// dictionary at cfg.DictPath, which is loaded before any input is read
+func runDictionaryCheck(stdin io.Reader, cfg *Config) error {
+	dict, err := loadDictionary(cfg.DictPath, frequencyOptions(cfg))
+	if err != nil {
+		return err
+	}
+
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		unknown, err := unknownWords(r, dict, cfg)
+		if err != nil {
+			return err
+		}
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		printUnknownWords(cfg, analyze.SortFrequencies(unknown, analyze.SortMode(cfg.SortMode), 0, frequencyOptions(cfg)))
+		return nil
+	})
+}
+
+// printUnknownWords prints every unknown word with its count in the same
+// layout as the word frequency table
+func printUnknownWords(cfg *Config, words []analyze.WordFrequency) {
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for _, wf := range words {
+			fmt.Fprintf(cfg.Output, "%s %s\n", wf.Word, formatCount(cfg, wf.Count))
+		}
+		return
+	}
+
+	maxWordLen := 0
+	for _, wf := range words {
+		if n := len([]rune(wf.Word)); n > maxWordLen {
+			maxWordLen = n
+		}
+	}
+
+	fmt.Fprintf(cfg.Output, "Words not in dictionary (sorted %s):\n", sortOrder(cfg))
+	fmt.Fprintf(cfg.Output, "%s  %s\n", strings.Repeat("-", maxWordLen), "------")
+	for _, wf := range words {
+		fmt.Fprintf(cfg.Output, "%-*s  %6s\n", maxWordLen, wf.Word, formatCount(cfg, wf.Count))
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// FreqDelta is how the count of one word changed from document A to document B
+type FreqDelta struct {
+	Word   string
+	CountA int
+	CountB int
+	Delta  int // CountB - CountA
+}
+
+// frequencyDiff counts the words in a and b, split and normalized as opts
+// selects, and returns those whose counts differ, largest change first with
+// ties in word order. Words only in a have a CountB of 0 and words only in b
+// a CountA of 0.
+func frequencyDiff(a, b io.Reader, opts analyze.FrequencyOptions) ([]FreqDelta, error) {
+	countsA, err := analyze.WordCounts(a, opts)
+	if err != nil {
+		return nil, err
+	}
+	countsB, err := analyze.WordCounts(b, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var deltas []FreqDelta
+	for word, countA := range countsA {
+		if countB := countsB[word]; countB != countA {
+			deltas = append(deltas, FreqDelta{Word: word, CountA: countA, CountB: countB, Delta: countB - countA})
+		}
+	}
+	for word, countB := range countsB {
+		if _, ok := countsA[word]; !ok {
+			deltas = append(deltas, FreqDelta{Word: word, CountB: countB, Delta: countB})
+		}
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		di, dj := abs(deltas[i].Delta), abs(deltas[j].Delta)
+		if di == dj {
+			return deltas[i].Word < deltas[j].Word
+		}
+		return di > dj
+	})
+	return deltas, nil
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// runDiff compares the word frequencies of the two files in cfg.Paths
+func runDiff(cfg *Config) error {
+	if len(cfg.Paths) != 2 {
+		return fmt.Errorf("--diff needs exactly two files")
+	}
+	pathA, pathB := cfg.Paths[0], cfg.Paths[1]
+
+	fileA, err := openPath(pathA)
+	if err != nil {
+		return err
+	}
+	defer fileA.Close()
+	fileB, err := openPath(pathB)
+	if err != nil {
+		return err
+	}
+	defer fileB.Close()
+
+	deltas, err := frequencyDiff(prepareReader(fileA, cfg), prepareReader(fileB, cfg), frequencyOptions(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to compare word frequency: %w", err)
+	}
+	if cfg.FrequencyLimit > 0 && len(deltas) > cfg.FrequencyLimit {
+		deltas = deltas[:cfg.FrequencyLimit]
+	}
+
+	printFrequencyDiff(cfg, deltas, pathA, pathB)
+	return nil
+}
+
+// printFrequencyDiff prints the changed words as a table with both counts and
+// the change, marking words that appear in only one of the files
+func printFrequencyDiff(cfg *Config, deltas []FreqDelta, pathA, pathB string) {
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for _, d := range deltas {
+			fmt.Fprintf(cfg.Output, "%s %d %d %+d\n", d.Word, d.CountA, d.CountB, d.Delta)
+		}
+		return
+	}
+
+	maxWordLen := len("Word")
+	for _, d := range deltas {
+		if n := len([]rune(d.Word)); n > maxWordLen {
+			maxWordLen = n
+		}
+	}
+
+	fmt.Fprintf(cfg.Output, "Word frequency diff (A: %s, B: %s, sorted by change):\n", pathA, pathB)
+	fmt.Fprintf(cfg.Output, "%-*s  %6s  %6s  %6s\n", maxWordLen, "Word", "A", "B", "Change")
+	for _, d := range deltas {
+		note := ""
+		switch {
+		case d.CountB == 0:
+			note = "  only in A"
+		case d.CountA == 0:
+			note = "  only in B"
+		}
+		fmt.Fprintf(cfg.Output, "%-*s  %6d  %6d  %+6d%s\n", maxWordLen, d.Word, d.CountA, d.CountB, d.Delta, note)
+	}
+}
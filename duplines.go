@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// LineCount is how many times a line appears in the input
+type LineCount struct {
+	Line  string
+	Count int
+}
+
+// duplicateLines counts the lines of r, ignoring trailing whitespace, and
+// returns those that appear more than once, most repeated first with ties in
+// line order. Blank lines are only counted when includeBlank is set.
+func duplicateLines(r io.Reader, includeBlank bool) ([]LineCount, error) {
+	reader := bufio.NewReader(r)
+	counts := make(map[string]int)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimRightFunc(line, unicode.IsSpace)
+			if line != "" || includeBlank {
+				counts[line]++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var dups []LineCount
+	for line, count := range counts {
+		if count > 1 {
+			dups = append(dups, LineCount{Line: line, Count: count})
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].Count == dups[j].Count {
+			return dups[i].Line < dups[j].Line
+		}
+		return dups[i].Count > dups[j].Count
+	})
+	return dups, nil
+}
+
+// runDuplicateLines prints the repeated lines in each input with their counts,
+// all of them unless cfg.FrequencyLimit is set
+func runDuplicateLines(stdin io.Reader, cfg *Config) error {
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		dups, err := duplicateLines(r, cfg.DupBlank)
+		if err != nil {
+			return fmt.Errorf("failed to read lines: %w", err)
+		}
+		if cfg.FrequencyLimit > 0 && len(dups) > cfg.FrequencyLimit {
+			dups = dups[:cfg.FrequencyLimit]
+		}
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		printDuplicateLines(cfg, dups)
+		return nil
+	})
+}
+
+// printDuplicateLines prints each repeated line after its count, like uniq -c
+func printDuplicateLines(cfg *Config, dups []LineCount) {
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for _, d := range dups {
+			fmt.Fprintf(cfg.Output, "%s %s\n", formatCount(cfg, d.Count), d.Line)
+		}
+		return
+	}
+
+	if !cfg.NoHeader {
+		fmt.Fprintf(cfg.Output, "Duplicate lines (sorted by count):\n")
+		fmt.Fprintf(cfg.Output, "%6s  %s\n", "Count", "Line")
+	}
+	for _, d := range dups {
+		fmt.Fprintf(cfg.Output, "%6s  %s\n", formatCount(cfg, d.Count), d.Line)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// inputEncodings are the encodings --encoding converts to UTF-8 before
+// anything is counted. UTF-8 needs no conversion, so it has no decoder.
+var inputEncodings = map[string]encoding.Encoding{
+	"utf8":        nil,
+	"latin1":      charmap.ISO8859_1,
+	"windows1252": charmap.Windows1252,
+	"utf16le":     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf16be":     unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+}
+
+// utf16Encoding decodes UTF-16 whose byte order is given by its byte order
+// mark, which is how inputs with no --encoding are read when they start with one
+var utf16Encoding = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+
+// normalizeEncodingName folds the spellings of an encoding name together, so
+// "UTF-16LE", "utf_16le" and "utf16le" all name the same encoding
+func normalizeEncodingName(name string) string {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	if name == "iso88591" {
+		return "latin1"
+	}
+	return name
+}
+
+// inputEncodingNames lists the names --encoding accepts, for error messages
+func inputEncodingNames() string {
+	names := make([]string, 0, len(inputEncodings))
+	for name := range inputEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// detectBOM reports which byte order mark r starts with, "UTF-8",
+// "UTF-16LE" or "UTF-16BE", or "none". The returned reader still has the
+// peeked bytes, so it reads the whole of r.
+func detectBOM(r io.Reader) (string, io.Reader) {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(len(utf8BOM))
+	switch {
+	case bytes.HasPrefix(prefix, utf8BOM):
+		return "UTF-8", br
+	case bytes.HasPrefix(prefix, utf16BOMs[0]):
+		return "UTF-16LE", br
+	case bytes.HasPrefix(prefix, utf16BOMs[1]):
+		return "UTF-16BE", br
+	}
+	return "none", br
+}
+
+// runBOMReport prints the byte order mark each input starts with for --bom.
+// The inputs are checked as they are, before any mark is dropped.
+func runBOMReport(cfg *Config) error {
+	return forEachRawInput(cfg.Input, cfg, func(r io.Reader, path string) error {
+		bom, _ := detectBOM(r)
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		fmt.Fprintf(cfg.Output, "BOM: %s\n", bom)
+		return nil
+	})
+}
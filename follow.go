@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultFollowInterval is how often --follow checks the file for new input
+const defaultFollowInterval = time.Second
+
+// followCounter keeps running line, word, character and byte counts over input
+// that arrives in pieces, giving the same answers as the analyze counting
+// functions would for everything written so far
+type followCounter struct {
+	lines    int  // Newlines seen
+	words    int  // Words started
+	chars    int  // Complete runes decoded
+	bytes    int  // Bytes written
+	inWord   bool // The last rune decoded was part of a word
+	lastByte byte
+	pending  []byte // Start of a UTF-8 sequence split across writes
+}
+
+func (fc *followCounter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	fc.bytes += len(p)
+	fc.lastByte = p[len(p)-1]
+
+	data := append(fc.pending, p...)
+	fc.pending = nil
+	for len(data) > 0 {
+		// Hold back a partial rune until the rest of it arrives
+		if !utf8.FullRune(data) {
+			fc.pending = append([]byte(nil), data...)
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+
+		fc.chars++
+		if r == '\n' {
+			fc.lines++
+		}
+		if unicode.IsSpace(r) {
+			fc.inWord = false
+		} else if !fc.inWord {
+			fc.inWord = true
+			fc.words++
+		}
+	}
+	return len(p), nil
+}
+
+// counts returns the line, word and character counts as if the input ended here
+func (fc *followCounter) counts() (lines, words, chars int) {
+	lines, words, chars = fc.lines, fc.words, fc.chars
+
+	// A last line without a newline still counts
+	if fc.bytes > 0 && fc.lastByte != '\n' {
+		lines++
+	}
+
+	// A partial rune at the end of the input counts one character per byte
+	if len(fc.pending) > 0 {
+		chars += len(fc.pending)
+		if !fc.inWord {
+			words++
+		}
+	}
+	return lines, words, chars
+}
+
+// followFlags are the only flags --follow accepts. The running counts are kept
+// over the raw bytes of the file as they arrive, so anything that needs the
+// whole input, decodes or filters it first, or changes the output can't apply.
+var followFlags = map[string]bool{
+	"-f": true, "--follow": true, "--follow-interval": true,
+	"-l": true, "--lines": true,
+	"-w": true, "--words": true,
+	"-c": true, "--chars": true,
+	"-b": true, "--bytes": true,
+}
+
+// checkFollowFlags returns an error naming the first flag in args that
+// --follow can't honour
+func checkFollowFlags(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--follow-interval":
+			i++
+		case followFlags[arg], !strings.HasPrefix(arg, "-"):
+		case len(arg) > 2 && arg[1] != '-' && strings.Trim(arg[1:], "lwcbf") == "":
+			// Bundled short flags like -lf
+		default:
+			return fmt.Errorf("--follow can't be used with %s; it only supports -l, -w, -c, -b and --follow-interval", arg)
+		}
+	}
+	return nil
+}
+
+// checkFollow reports whether cfg names something --follow can keep up to date
+func checkFollow(cfg *Config) error {
+	if len(cfg.Paths) != 1 {
+		return fmt.Errorf("--follow needs exactly one file")
+	}
+	return nil
+}
+
+// followFile counts the file in cfg.Paths like tail -f, polling it every
+// cfg.FollowInterval and printing the running counts whenever it grows until
+// a value arrives on interrupt. A file that is truncated or replaced, as when
+// a log is rotated, is counted again from the start.
+func followFile(cfg *Config, interrupt <-chan os.Signal) error {
+	if err := checkFollow(cfg); err != nil {
+		return err
+	}
+	path := cfg.Paths[0]
+
+	interval := cfg.FollowInterval
+	if interval <= 0 {
+		interval = defaultFollowInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var file *os.File
+	var info os.FileInfo
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	counter := &followCounter{}
+	shown := -1
+	for {
+		current, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err) && file != nil:
+			// Rotated away; keep reading the old file until a new one appears
+		case err != nil:
+			return fmt.Errorf("failed to get file info for %s: %w", path, err)
+		case file == nil || !os.SameFile(info, current) || current.Size() < int64(counter.bytes):
+			if file != nil {
+				file.Close()
+			}
+			file, err = os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s: %w", path, err)
+			}
+			info = current
+			counter = &followCounter{}
+			shown = -1
+		}
+
+		if _, err := io.Copy(counter, file); err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		// Only print when there's something new to report
+		if counter.bytes != shown {
+			printFollowCounts(cfg, counter, path)
+			shown = counter.bytes
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printFollowCounts prints the running counts in the same layout as a normal count
+func printFollowCounts(cfg *Config, counter *followCounter, path string) {
+	lines, words, chars := counter.counts()
+	if cfg.Line && cfg.Word && cfg.Char {
+		printCounts(cfg, lines, words, chars, path)
+		return
+	}
+
+	var count int
+	switch {
+	case cfg.Line:
+		count = lines
+	case cfg.Char:
+		count = chars
+	case cfg.Byte:
+		count = counter.bytes
+	case cfg.Word:
+		count = words
+	}
+	printCount(cfg, count, path)
+}
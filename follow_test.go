@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+func TestFollowCounter(t *testing.T) {
+	inputs := []string{
+		"",
+		"one",
+		"one two\nthree\n",
+		"  leading and trailing  \n\n",
+		"naïve café — 日本語\nsecond line",
+		"broken \xe6\x97 rune",
+	}
+
+	for _, input := range inputs {
+		// Feed the input in every chunk size so words and runes get split across writes
+		for size := 1; size <= len(input)+1; size++ {
+			counter := &followCounter{}
+			for start := 0; start < len(input); start += size {
+				end := start + size
+				if end > len(input) {
+					end = len(input)
+				}
+				counter.Write([]byte(input[start:end]))
+			}
+
+			lines, words, chars := counter.counts()
+			expectedLines := analyze.CountLines(strings.NewReader(input))
+			expectedWords := analyze.CountWords(strings.NewReader(input))
+			expectedChars := analyze.CountChars(strings.NewReader(input))
+			if lines != expectedLines || words != expectedWords || chars != expectedChars || counter.bytes != len(input) {
+				t.Errorf("%q in chunks of %d: got %d %d %d %d, want %d %d %d %d", input, size,
+					lines, words, chars, counter.bytes, expectedLines, expectedWords, expectedChars, len(input))
+			}
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that followFile can write to while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+// waitForOutput polls out until it ends with want, so the test follows the
+// counts as they're printed instead of guessing how long a poll takes
+func waitForOutput(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasSuffix(out.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %q, got %q", want, out.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("one two\n"), 0644); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+
+	outBuf := &syncBuffer{}
+	cfg := &Config{Line: true, Word: true, Char: true, Follow: true, FollowInterval: 5 * time.Millisecond, Paths: []string{path}, Output: outBuf}
+	interrupt := make(chan os.Signal, 1)
+	done := make(chan error)
+	go func() {
+		done <- followFile(cfg, interrupt)
+	}()
+
+	// Grow the file, truncate it, then replace it as log rotation would,
+	// waiting for each change to be reported before making the next
+	waitForOutput(t, outBuf, "       1       2       8 "+path+"\n")
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open temp file: %v", err)
+	}
+	file.WriteString("three\n")
+	file.Close()
+	waitForOutput(t, outBuf, "       2       3      14 "+path+"\n")
+	if err := os.Truncate(path, 4); err != nil {
+		t.Fatalf("Failed to truncate temp file: %v", err)
+	}
+	waitForOutput(t, outBuf, "       1       1       4 "+path+"\n")
+	rotated := path + ".new"
+	if err := os.WriteFile(rotated, []byte("x\n"), 0644); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	if err := os.Rename(rotated, path); err != nil {
+		t.Fatalf("Failed to replace temp file: %v", err)
+	}
+	waitForOutput(t, outBuf, "       1       1       2 "+path+"\n")
+
+	interrupt <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatalf("followFile returned error: %v", err)
+	}
+
+	expected := "       1       2       8 " + path + "\n" +
+		"       2       3      14 " + path + "\n" +
+		"       1       1       4 " + path + "\n" +
+		"       1       1       2 " + path + "\n"
+	if outBuf.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, outBuf.String())
+	}
+}
+
+func TestFollowRejectsOtherModes(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"freq", []string{"--freq"}, "can't be used with --freq"},
+		{"lang", []string{"--lang"}, "can't be used with --lang"},
+		{"loc", []string{"--loc"}, "can't be used with --loc"},
+		{"sentences", []string{"--sentences"}, "can't be used with --sentences"},
+		{"max line length", []string{"-lL"}, "can't be used with -lL"},
+		{"repl", []string{"--repl"}, "can't be used with --repl"},
+		{"diff", []string{"--diff"}, "can't be used with --diff"},
+		{"sum", []string{"--sum"}, "can't be used with --sum"},
+		{"dict", []string{"--dict", "words.txt"}, "can't be used with --dict"},
+		{"hash", []string{"--hash", "md5"}, "can't be used with --hash"},
+		{"tokens", []string{"--tokens"}, "can't be used with --tokens"},
+		{"dup-lines", []string{"--dup-lines"}, "can't be used with --dup-lines"},
+		{"bom", []string{"--bom"}, "can't be used with --bom"},
+		{"anagrams", []string{"--anagrams"}, "can't be used with --anagrams"},
+		{"line-endings", []string{"--line-endings"}, "can't be used with --line-endings"},
+		{"word-mode", []string{"-w", "--word-mode", "alpha"}, "can't be used with --word-mode"},
+		{"ndjson", []string{"--ndjson"}, "can't be used with --ndjson"},
+		{"count-word", []string{"--count-word", "the"}, "can't be used with --count-word"},
+		{"json-out", []string{"--json-out", "out.json"}, "can't be used with --json-out"},
+		{"tab-width", []string{"-c", "--tab-width", "4"}, "can't be used with --tab-width"},
+		{"whitespace-report", []string{"--whitespace-report"}, "can't be used with --whitespace-report"},
+		{"cjk", []string{"-w", "--cjk"}, "can't be used with --cjk"},
+		{"grep", []string{"--grep", "a"}, "can't be used with --grep"},
+		{"line-freq", []string{"--line-freq"}, "can't be used with --line-freq"},
+		{"strip-frontmatter", []string{"--strip-frontmatter"}, "can't be used with --strip-frontmatter"},
+		{"encoding", []string{"--encoding", "utf16le"}, "can't be used with --encoding"},
+		{"normalize", []string{"--normalize"}, "can't be used with --normalize"},
+		{"sample", []string{"--sample", "10"}, "can't be used with --sample"},
+		{"sample-lines", []string{"--sample-lines", "1"}, "can't be used with --sample-lines"},
+		{"stdin", nil, "exactly one file"},
+		{"two files", []string{"b"}, "exactly one file"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Args = append([]string{"lexo", "-f"}, tc.args...)
+			if tc.name != "stdin" {
+				os.Args = append(os.Args, "a")
+			}
+			cfg := NewDefaultConfig()
+			err := ParseFlags(cfg)
+			if err == nil {
+				err = Run(cfg)
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestFollowFlags(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	os.Args = []string{"lexo", "-lf", "--follow-interval", "250ms", "app.log"}
+	cfg := NewDefaultConfig()
+	if err := ParseFlags(cfg); err != nil {
+		t.Fatalf("ParseFlags returned error: %v", err)
+	}
+	if !cfg.Follow || !cfg.Line || cfg.FollowInterval != 250*time.Millisecond {
+		t.Errorf("Expected Follow and Line with a 250ms interval, got %+v", cfg)
+	}
+
+	for _, interval := range []string{"soon", "0s"} {
+		os.Args = []string{"lexo", "--follow", "--follow-interval", interval, "app.log"}
+		err := ParseFlags(NewDefaultConfig())
+		if err == nil || !strings.Contains(err.Error(), "invalid --follow-interval") {
+			t.Errorf("Expected an invalid interval error for %q, got %v", interval, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignorePattern is a single rule read from a .gitignore file
+type gitignorePattern struct {
+	base     string // Directory holding the .gitignore the rule came from
+	pattern  string // Glob with any leading "!", leading "/" and trailing "/" removed
+	negate   bool   // Rule re-includes paths matched by earlier rules
+	dirOnly  bool   // Rule only matches directories
+	anchored bool   // Rule matches the path relative to base rather than just the name
+}
+
+// readGitignore reads the .gitignore in dir, if there is one
+func readGitignore(dir string) ([]gitignorePattern, error) {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open .gitignore in %s: %w", dir, err)
+	}
+	defer file.Close()
+
+	patterns, err := parseGitignore(file, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitignore in %s: %w", dir, err)
+	}
+	return patterns, nil
+}
+
+// parseGitignore parses .gitignore rules, supporting plain globs such as
+// "*.log", directory rules such as "build/", rules anchored with a leading
+// "/" or an inner slash, and "!" negation. Blank lines and comments are skipped.
+func parseGitignore(r io.Reader, base string) ([]gitignorePattern, error) {
+	var patterns []gitignorePattern
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := gitignorePattern{base: base}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		p.pattern = line
+		patterns = append(patterns, p)
+	}
+
+	return patterns, scanner.Err()
+}
+
+// matches reports whether the rule applies to the given path
+func (p gitignorePattern) matches(entryPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(p.base, entryPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if !p.anchored {
+		rel = path.Base(rel)
+	}
+	matched, _ := path.Match(p.pattern, rel)
+	return matched
+}
+
+// isIgnored reports whether the path is excluded by the rules. As in git,
+// later rules override earlier ones, so a "!" rule can re-include a path.
+func isIgnored(patterns []gitignorePattern, entryPath string, isDir bool) bool {
+	ignored := false
+	for _, p := range patterns {
+		if p.matches(entryPath, isDir) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitignore(t *testing.T) {
+	input := "# comment\n\n*.log\nbuild/\n/root.txt\ndocs/*.md\n!keep.log\n"
+	patterns, err := parseGitignore(strings.NewReader(input), "repo")
+	if err != nil {
+		t.Fatalf("parseGitignore returned error: %v", err)
+	}
+
+	expected := []gitignorePattern{
+		{base: "repo", pattern: "*.log"},
+		{base: "repo", pattern: "build", dirOnly: true},
+		{base: "repo", pattern: "root.txt", anchored: true},
+		{base: "repo", pattern: "docs/*.md", anchored: true},
+		{base: "repo", pattern: "keep.log", negate: true},
+	}
+	if len(patterns) != len(expected) {
+		t.Fatalf("Expected %d patterns, got %d: %+v", len(expected), len(patterns), patterns)
+	}
+	for i := range expected {
+		if patterns[i] != expected[i] {
+			t.Errorf("Pattern %d: expected %+v, got %+v", i, expected[i], patterns[i])
+		}
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	patterns, _ := parseGitignore(strings.NewReader("*.log\nbuild/\n/root.txt\ndocs/*.md\n!keep.log\n"), "repo")
+
+	testCases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"repo/debug.log", false, true},
+		{"repo/sub/debug.log", false, true},
+		{"repo/keep.log", false, false},
+		{"repo/build", true, true},
+		{"repo/sub/build", true, true},
+		{"repo/build", false, false},
+		{"repo/root.txt", false, true},
+		{"repo/sub/root.txt", false, false},
+		{"repo/docs/guide.md", false, true},
+		{"repo/sub/docs/guide.md", false, false},
+		{"repo/main.go", false, false},
+		{"other/debug.log", false, false},
+	}
+
+	for _, tc := range testCases {
+		if actual := isIgnored(patterns, tc.path, tc.isDir); actual != tc.expected {
+			t.Errorf("isIgnored(%q, %v): expected %v, got %v", tc.path, tc.isDir, tc.expected, actual)
+		}
+	}
+}
+
+// TestRespectGitignore tests that --respect-gitignore filters directory scans for --loc
+func TestRespectGitignore(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		".gitignore":            "gen/\n*.js\n",
+		"main.go":               "package main\nfunc main() {}\n",
+		"app.js":                "console.log(1)\n",
+		"gen/types.go":          "package gen\n",
+		"sub/.gitignore":        "!keep.js\n",
+		"sub/keep.js":           "console.log(2)\n",
+		"sub/drop.js":           "console.log(3)\n",
+		"sub/gen/more.go":       "package gen\n",
+		"sub/nested/ignored.js": "console.log(4)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Could not create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write test file: %v", err)
+		}
+	}
+
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+	os.Args = []string{"lexo", "--loc", "--respect-gitignore", tempDir}
+	cfg := NewDefaultConfig()
+	ParseFlags(cfg)
+	if !cfg.RespectGitignore {
+		t.Fatalf("Expected RespectGitignore to be set")
+	}
+
+	// Only main.go and sub/keep.js survive the root and nested rules
+	var outBuf bytes.Buffer
+	cfg.Output = &outBuf
+	if err := Run(cfg); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if outBuf.String() != "3\n" {
+		t.Errorf("Expected 3 code lines, got %q", outBuf.String())
+	}
+
+	// Without the flag every file is counted
+	outBuf.Reset()
+	cfg.RespectGitignore = false
+	if err := Run(cfg); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if outBuf.String() != "8\n" {
+		t.Errorf("Expected 8 code lines, got %q", outBuf.String())
+	}
+}
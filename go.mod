@@ -2,7 +2,10 @@ module cloudartisan.com/lexo
 
 go 1.20
 
-require github.com/abadojack/whatlanggo v1.0.1
+require (
+	github.com/abadojack/whatlanggo v1.0.1
+	golang.org/x/text v0.3.8
+)
 
 // Force correct versions
 replace (
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// countMatchingLines counts the lines in r that re matches, or with invert
+// the lines it doesn't match, like grep -c and grep -vc
+func countMatchingLines(r io.Reader, re *regexp.Regexp, invert bool) (int, error) {
+	reader := bufio.NewReader(r)
+	count := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" && re.MatchString(strings.TrimSuffix(line, "\n")) != invert {
+			count++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// runGrep prints how many lines of each input match --grep (or don't, with
+// --grep-invert), with a total for several files
+func runGrep(stdin io.Reader, cfg *Config) error {
+	re := cfg.GrepRegexp
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(cfg.GrepPattern); err != nil {
+			return fmt.Errorf("invalid --grep %q: %w", cfg.GrepPattern, err)
+		}
+	}
+
+	total := 0
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		count, err := countMatchingLines(r, re, cfg.GrepInvert)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		printCount(cfg, count, path)
+		total += count
+		return nil
+	})
+	// With --keep-going the error only says some files failed, so the total
+	// still covers the rest
+	if err != nil && !cfg.KeepGoing {
+		return err
+	}
+
+	if len(cfg.Paths) > 1 {
+		printCount(cfg, total, "total")
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hashAlgorithms are the digests --hash can print alongside the counts
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// hashAlgorithmNames lists the names --hash accepts, for error messages
+func hashAlgorithmNames() string {
+	names := make([]string, 0, len(hashAlgorithms))
+	for name := range hashAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// openHashed opens path like openPath, teeing the file's own bytes into the
+// digest selected by cfg.HashAlgo before they're decompressed or preprocessed,
+// so the digest matches md5sum or sha256sum. The label function works as it
+// does for hashInput.
+func openHashed(path string, cfg *Config) (io.ReadCloser, func(path string) string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+
+	raw, label := hashInput(file, cfg)
+	r, err := decompress(raw, path)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return &fileReader{Reader: r, file: file}, label, nil
+}
+
+// hashInput tees r into the digest selected by cfg.HashAlgo as it's counted.
+// It returns the reader to count and a function that adds the hex digest of
+// the whole input in front of path (empty for stdin) for the output line.
+// Without --hash the reader is r and the path is left alone.
+func hashInput(r io.Reader, cfg *Config) (io.Reader, func(path string) string) {
+	newHash, ok := hashAlgorithms[cfg.HashAlgo]
+	if !ok {
+		return r, func(path string) string { return path }
+	}
+
+	digest := newHash()
+	tee := io.TeeReader(r, digest)
+	return tee, func(path string) string {
+		// Hash anything the counter didn't need to read
+		io.Copy(io.Discard, tee)
+
+		sum := hex.EncodeToString(digest.Sum(nil))
+		if path == "" {
+			return sum
+		}
+		return sum + " " + path
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+// fileErrors decides what a per-file error does to a run. By default the
+// first error stops it; with --keep-going each one is reported on
+// cfg.ErrorOutput and the run carries on with the next file, failing at the
+// end if any file did.
+type fileErrors struct {
+	cfg    *Config
+	failed int
+}
+
+// check returns err if it should stop the run, or reports it and returns nil
+// with --keep-going
+func (fe *fileErrors) check(err error) error {
+	if err == nil || !fe.cfg.KeepGoing {
+		return err
+	}
+	fmt.Fprintf(fe.cfg.ErrorOutput, "Error: %v\n", err)
+	fe.failed++
+	return nil
+}
+
+// err reports how many files failed once every file has been tried
+func (fe *fileErrors) err() error {
+	if fe.failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to process %d of %d files", fe.failed, len(fe.cfg.Paths))
+}
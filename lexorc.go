@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFileName is the file default flags are read from
+const configFileName = ".lexorc"
+
+// longFlagNames maps each short flag to its long spelling, so a setting in
+// .lexorc and a flag on the command line are matched whichever one they use
+var longFlagNames = map[string]string{
+	"-R": "--recursive",
+	"-l": "--lines",
+	"-c": "--chars",
+	"-b": "--bytes",
+	"-L": "--max-line-length",
+	"-w": "--words",
+	"-0": "--null",
+	"-r": "--reverse",
+	"-q": "--quiet",
+	"-f": "--follow",
+}
+
+// modeFlags are the flags that choose what lexo reports. A run reports one
+// thing, so a mode on the command line replaces every mode set in .lexorc.
+var modeFlags = map[string]bool{
+	"--lines": true, "--words": true, "--chars": true, "--bytes": true,
+	"--sentences": true, "--unique": true, "--distinct": true, "--max-line-length": true, "--avg-line-length": true,
+	"--stats": true, "--readability": true, "--density": true, "--summary": true, "--ttr": true,
+	"--entropy": true, "--entropy-bytes": true, "--caps-stats": true, "--length-histogram": true, "--line-length-histogram": true,
+	"--loc": true, "--loc-verbose": true, "--list-files": true, "--comment-ratio": true, "--manifest": true,
+	"--lang": true, "--lang-name": true, "--lang-confidence": true, "--lang-summary": true, "--script": true, "--lang-candidates": true,
+	"--freq": true, "--char-freq": true, "--char-whitespace": true, "--include-spaces": true, "--top-chars": true,
+	"--cloud": true, "--with-position": true, "--suffix": true, "--prefix": true,
+	"--repl": true, "--diff": true, "--concat": true, "--sum": true, "--hash": true, "--follow": true,
+	"--dict": true, "--count-word": true, "--grep": true, "--tokens": true, "--anagrams": true,
+	"--dup-lines": true, "--line-freq": true, "--line-endings": true, "--whitespace-report": true, "--bom": true,
+}
+
+// longFlagName returns the long spelling of flag, or flag itself if it has none
+func longFlagName(flag string) string {
+	if long, ok := longFlagNames[flag]; ok {
+		return long
+	}
+	return flag
+}
+
+// commandLineFlags returns args without any --flag=false, which only turns
+// off a flag set in .lexorc, along with the long names of the flags args
+// mentions: true for those it sets and false for those it turns off
+func commandLineFlags(args []string) ([]string, map[string]bool) {
+	var remaining []string
+	set := make(map[string]bool)
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name := arg
+			if eq := strings.IndexByte(arg, '='); eq >= 0 {
+				name = arg[:eq]
+				if arg[eq+1:] == "false" {
+					set[longFlagName(name)] = false
+					continue
+				}
+			}
+			set[longFlagName(name)] = true
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Bundled short flags like -lw set each letter
+			for _, letter := range arg[1:] {
+				set[longFlagName("-"+string(letter))] = true
+			}
+		}
+		remaining = append(remaining, arg)
+	}
+	return remaining, set
+}
+
+// configArgs flattens the settings in cfg.DefaultArgs into flags, leaving
+// out those the command line overrides: any it sets or turns off itself,
+// every mode when it picks one of its own, and any it can't be used with
+func configArgs(cfg *Config, cmdline []string, set map[string]bool) []string {
+	cmdlineMode := false
+	for flag, on := range set {
+		if on && modeFlags[flag] {
+			cmdlineMode = true
+		}
+	}
+
+	// Whether flags can be used together is left to parseArgs, but only
+	// for a file that's valid on its own, so its own mistakes are reported
+	var all []string
+	for _, setting := range cfg.DefaultArgs {
+		all = append(all, setting...)
+	}
+	valid := parses(cfg, all)
+
+	var args []string
+	for _, setting := range cfg.DefaultArgs {
+		flag := longFlagName(setting[0])
+		if _, ok := set[flag]; ok || (cmdlineMode && modeFlags[flag]) {
+			continue
+		}
+		with := append(append([]string(nil), args...), setting...)
+		if valid && !parses(cfg, append(with, cmdline...)) {
+			continue
+		}
+		args = with
+	}
+	return args
+}
+
+// parses reports whether args parse without error, leaving cfg untouched
+func parses(cfg *Config, args []string) bool {
+	trial := *cfg
+	trial.ErrorOutput = io.Discard
+	return parseArgs(&trial, args) == nil
+}
+
+// loadConfigFile reads default flags from .lexorc in the current directory,
+// or from $HOME when there isn't one, into cfg.DefaultArgs. Only the first
+// file found is read, and a missing file isn't an error.
+func loadConfigFile(cfg *Config) error {
+	paths := []string{configFileName}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, configFileName))
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		args, err := parseConfigFile(string(data), path)
+		if err != nil {
+			return err
+		}
+		cfg.DefaultArgs = args
+		return nil
+	}
+	return nil
+}
+
+// parseConfigFile turns the "key = value" lines of a config file into the
+// equivalent flags, one setting at a time, so "sort-count = true" becomes
+// --sort-count and "limit = 20" becomes --limit 20. Keys are flag names
+// without the dashes. Values may be quoted as in TOML, "false" leaves a flag
+// out, and blank lines, # comments and [section] headers are skipped.
+func parseConfigFile(data, path string) ([][]string, error) {
+	var args [][]string
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value, got %q", path, n+1, line)
+		}
+		key := strings.TrimLeft(strings.TrimSpace(line[:eq]), "-")
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: missing key before =", path, n+1)
+		}
+		value := configValue(strings.TrimSpace(line[eq+1:]))
+
+		// Single letters are short flags like -q
+		flag := "--" + key
+		if len(key) == 1 {
+			flag = "-" + key
+		}
+
+		switch value {
+		case "true":
+			args = append(args, []string{flag})
+		case "false":
+		default:
+			args = append(args, []string{flag, value})
+		}
+	}
+	return args, nil
+}
+
+// configValue strips the quotes from a quoted value, or a trailing # comment
+// from an unquoted one
+func configValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
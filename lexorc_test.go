@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	data := `# lexo defaults
+[lexo]
+sort-count = true
+limit = 20   # top twenty
+no-stopwords = false
+trim-chars = ".,#"
+q = true
+
+--sort = 'length'
+`
+	args, err := parseConfigFile(data, ".lexorc")
+	if err != nil {
+		t.Fatalf("parseConfigFile returned error: %v", err)
+	}
+	expected := [][]string{{"--sort-count"}, {"--limit", "20"}, {"--trim-chars", ".,#"}, {"-q"}, {"--sort", "length"}}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+
+	for _, bad := range []string{"limit 20", " = true"} {
+		_, err := parseConfigFile(bad, ".lexorc")
+		if err == nil || !strings.Contains(err.Error(), ".lexorc:1") {
+			t.Errorf("Expected an error naming the line for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	oldArgs := os.Args
+	oldHome := os.Getenv("HOME")
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer func() {
+		os.Args = oldArgs
+		os.Setenv("HOME", oldHome)
+		os.Chdir(oldDir)
+	}()
+
+	home := t.TempDir()
+	work := t.TempDir()
+	os.Setenv("HOME", home)
+	if err := os.Chdir(work); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, configFileName), []byte("freq = true\nlimit = 20\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	// $HOME/.lexorc is used when there's none in the current directory, and
+	// the command line overrides it
+	os.Args = []string{"lexo", "--limit", "5"}
+	cfg := NewDefaultConfig()
+	if err := loadConfigFile(cfg); err != nil {
+		t.Fatalf("loadConfigFile returned error: %v", err)
+	}
+	if err := ParseFlags(cfg); err != nil {
+		t.Fatalf("ParseFlags returned error: %v", err)
+	}
+	if !cfg.FrequencyAnalysis || cfg.FrequencyLimit != 5 {
+		t.Errorf("Expected --freq from $HOME/.lexorc with the command line's limit of 5, got %+v", cfg)
+	}
+
+	// A .lexorc in the current directory replaces the one in $HOME
+	if err := os.WriteFile(configFileName, []byte("limit = 3\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	os.Args = []string{"lexo"}
+	cfg = NewDefaultConfig()
+	if err := loadConfigFile(cfg); err != nil {
+		t.Fatalf("loadConfigFile returned error: %v", err)
+	}
+	if err := ParseFlags(cfg); err != nil {
+		t.Fatalf("ParseFlags returned error: %v", err)
+	}
+	if cfg.FrequencyAnalysis || cfg.FrequencyLimit != 3 {
+		t.Errorf("Expected only the current directory's limit of 3, got %+v", cfg)
+	}
+}
+
+func TestCommandLineOverridesConfigFile(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	testCases := []struct {
+		name     string
+		settings [][]string
+		args     []string
+		check    func(cfg *Config) bool
+	}{
+		{"clashing flag wins", [][]string{{"--csv"}}, []string{"lexo", "--tsv"},
+			func(cfg *Config) bool { return cfg.TSVOutput && !cfg.CSVOutput }},
+		{"clashing pair wins either way round", [][]string{{"--tail", "2"}}, []string{"lexo", "--head", "1"},
+			func(cfg *Config) bool { return cfg.Head == 1 && cfg.Tail == 0 }},
+		{"false turns a flag off", [][]string{{"--no-stopwords"}, {"--freq"}}, []string{"lexo", "--no-stopwords=false"},
+			func(cfg *Config) bool { return !cfg.FilterStopwords && cfg.FrequencyAnalysis }},
+		{"short and long spellings match", [][]string{{"-q"}}, []string{"lexo", "--quiet=false"},
+			func(cfg *Config) bool { return !cfg.Quiet }},
+		{"list replaced rather than added to", [][]string{{"--exclude", "*.log"}}, []string{"lexo", "--exclude", "*.tmp"},
+			func(cfg *Config) bool { return reflect.DeepEqual(cfg.ExcludePatterns, []string{"*.tmp"}) }},
+		{"turning off a clashing flag keeps the setting", [][]string{{"--csv"}}, []string{"lexo", "--tsv=false"},
+			func(cfg *Config) bool { return cfg.CSVOutput && !cfg.TSVOutput }},
+		{"mode replaces the file's mode", [][]string{{"--lang"}, {"--tokens"}, {"--grep", "x"}, {"--grep-invert"}}, []string{"lexo", "--freq"},
+			func(cfg *Config) bool {
+				return cfg.FrequencyAnalysis && !cfg.DetectLanguage && !cfg.Tokens && cfg.GrepPattern == "" && !cfg.GrepInvert
+			}},
+		{"mode replaces modes it could be used with", [][]string{{"--hash", "md5"}, {"--distinct"}}, []string{"lexo", "--freq"},
+			func(cfg *Config) bool { return cfg.FrequencyAnalysis && cfg.HashAlgo == "" && !cfg.Distinct }},
+		{"counts replace the file's mode", [][]string{{"--freq"}}, []string{"lexo", "-lw"},
+			func(cfg *Config) bool { return cfg.Line && cfg.Word && !cfg.FrequencyAnalysis }},
+		{"settings follow can't use dropped", [][]string{{"--human"}, {"--freq"}}, []string{"lexo", "-f", "app.log"},
+			func(cfg *Config) bool { return cfg.Follow && !cfg.Human && !cfg.FrequencyAnalysis }},
+		{"unrelated settings kept", [][]string{{"--csv"}, {"--limit", "20"}}, []string{"lexo", "--freq"},
+			func(cfg *Config) bool { return cfg.CSVOutput && cfg.FrequencyLimit == 20 }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Args = tc.args
+			cfg := NewDefaultConfig()
+			cfg.DefaultArgs = tc.settings
+			if err := ParseFlags(cfg); err != nil {
+				t.Fatalf("ParseFlags returned error: %v", err)
+			}
+			if !tc.check(cfg) {
+				t.Errorf("Unexpected configuration for %v over %v: %+v", tc.args, tc.settings, cfg)
+			}
+		})
+	}
+
+	// Clashing flags given together on the command line are still an error,
+	// as is a setting that's wrong on its own
+	os.Args = []string{"lexo", "--csv", "--tsv"}
+	cfg := NewDefaultConfig()
+	cfg.DefaultArgs = [][]string{{"--csv"}}
+	if err := ParseFlags(cfg); err == nil {
+		t.Error("Expected --csv and --tsv on the command line to return an error")
+	}
+	os.Args = []string{"lexo", "--freq"}
+	cfg = NewDefaultConfig()
+	cfg.DefaultArgs = [][]string{{"--sort", "bogus"}}
+	if err := ParseFlags(cfg); err == nil {
+		t.Error("Expected an invalid --sort in the config file to return an error")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// lineEndingStats counts the LF, CRLF and lone CR line endings in r
+func lineEndingStats(r io.Reader) (lf, crlf, cr int, err error) {
+	reader := bufio.NewReader(r)
+	afterCR := false
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		switch {
+		case b == '\n' && afterCR:
+			crlf++
+		case b == '\n':
+			lf++
+		case afterCR:
+			cr++
+		}
+		afterCR = b == '\r'
+	}
+
+	// A CR at the very end has nothing after it
+	if afterCR {
+		cr++
+	}
+	return lf, crlf, cr, nil
+}
+
+// formatLineEndings describes the line endings counted in a file, most used
+// first, such as "CRLF: 10, LF: 2 (mixed)"
+func formatLineEndings(lf, crlf, cr int) string {
+	type ending struct {
+		name  string
+		count int
+	}
+	var found []ending
+	for _, e := range []ending{{"LF", lf}, {"CRLF", crlf}, {"CR", cr}} {
+		if e.count > 0 {
+			found = append(found, e)
+		}
+	}
+	if len(found) == 0 {
+		return "none (no line terminators)"
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].count > found[j].count })
+
+	parts := make([]string, len(found))
+	for i, e := range found {
+		parts[i] = fmt.Sprintf("%s: %d", e.name, e.count)
+	}
+	description := strings.Join(parts, ", ")
+	if len(found) > 1 {
+		description += " (mixed)"
+	}
+	return description
+}
+
+// runLineEndings reports the line endings of each input
+func runLineEndings(stdin io.Reader, cfg *Config) error {
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		lf, crlf, cr, err := lineEndingStats(r)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		fmt.Fprintf(cfg.Output, "Line endings: %s\n", formatLineEndings(lf, crlf, cr))
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// analyzeLineFrequency counts each distinct line of r, without its line ending
+// and trimmed of surrounding whitespace with --trim-lines, and returns them
+// sorted and limited like --freq, most frequent first unless --sort says otherwise
+func analyzeLineFrequency(r io.Reader, cfg *Config) ([]LineCount, error) {
+	reader := bufio.NewReader(r)
+	counts := make(map[string]int)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if cfg.TrimLines {
+				line = strings.TrimSpace(line)
+			}
+			counts[line]++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mode := analyze.SortMode(cfg.SortMode)
+	if mode == "" {
+		mode = analyze.SortCount
+	}
+
+	var lines []LineCount
+	for _, lf := range analyze.SortFrequencies(counts, mode, frequencyLimit(cfg), frequencyOptions(cfg)) {
+		lines = append(lines, LineCount{Line: lf.Word, Count: lf.Count})
+	}
+	return lines, nil
+}
+
+// runLineFrequency prints the most frequent lines in each input with their counts
+func runLineFrequency(stdin io.Reader, cfg *Config) error {
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		lines, err := analyzeLineFrequency(r, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to read lines: %w", err)
+		}
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		printLineFrequency(cfg, lines)
+		return nil
+	})
+}
+
+// printLineFrequency prints each line after its count, like uniq -c, cutting
+// lines longer than --max-col-width short for display
+func printLineFrequency(cfg *Config, lines []LineCount) {
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for _, lc := range lines {
+			fmt.Fprintf(cfg.Output, "%s %s\n", formatCount(cfg, lc.Count), truncateWord(lc.Line, cfg.MaxColWidth))
+		}
+		return
+	}
+
+	if !cfg.NoHeader {
+		fmt.Fprintf(cfg.Output, "Line frequency (sorted %s):\n", lineSortOrder(cfg))
+		fmt.Fprintf(cfg.Output, "%6s  %s\n", "Count", "Line")
+	}
+	for _, lc := range lines {
+		fmt.Fprintf(cfg.Output, "%6s  %s\n", formatCount(cfg, lc.Count), truncateWord(lc.Line, cfg.MaxColWidth))
+	}
+}
+
+// lineSortOrder describes the order --line-freq sorts lines in, which is by
+// count unless --sort picks another
+func lineSortOrder(cfg *Config) string {
+	if cfg.SortMode == "" {
+		sorted := *cfg
+		sorted.SortMode = string(analyze.SortCount)
+		return sortOrder(&sorted)
+	}
+	return sortOrder(cfg)
+}
@@ -3,224 +3,53 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/abadojack/whatlanggo"
+	"cloudartisan.com/lexo/analyze"
+	"golang.org/x/text/transform"
 )
 
-func countWords(r io.Reader) int {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanWords)
-
-	wc := 0
-	for scanner.Scan() {
-		wc++
-	}
-
-	return wc
-}
-
-// WordFrequency represents a word and its frequency count
-type WordFrequency struct {
-	Word  string
-	Count int
-}
-
-// analyzeWordFrequency counts the frequency of each word in the text
-// and returns the results sorted by frequency (highest first) or alphabetically
-func analyzeWordFrequency(r io.Reader, sortByCount bool, limit int) ([]WordFrequency, error) {
-	// If limit is 0 or negative, set a reasonable default
-	if limit <= 0 {
-		limit = 10
-	}
-
-	// Create a scanner to read words
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanWords)
-
-	// Use a map to count word frequencies
-	wordCounts := make(map[string]int)
-
-	// Process each word
-	for scanner.Scan() {
-		word := scanner.Text()
-		
-		// Convert to lowercase for case-insensitive counting
-		word = strings.ToLower(word)
-		
-		// Remove any punctuation at the start or end of the word
-		word = strings.Trim(word, ".,;:!?\"'()[]{}")
-		
-		// Skip empty strings after trimming
-		if word == "" {
-			continue
-		}
-		
-		// Increment the word count
-		wordCounts[word]++
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	// Convert map to slice for sorting
-	var frequencies []WordFrequency
-	for word, count := range wordCounts {
-		frequencies = append(frequencies, WordFrequency{Word: word, Count: count})
-	}
-
-	// Sort the frequencies
-	if sortByCount {
-		// Sort by count (descending) with alphabetical tiebreaker
-		sort.Slice(frequencies, func(i, j int) bool {
-			if frequencies[i].Count == frequencies[j].Count {
-				return frequencies[i].Word < frequencies[j].Word
-			}
-			return frequencies[i].Count > frequencies[j].Count
-		})
-	} else {
-		// Sort alphabetically
-		sort.Slice(frequencies, func(i, j int) bool {
-			return frequencies[i].Word < frequencies[j].Word
-		})
-	}
-
-	// Apply limit
-	if limit > 0 && limit < len(frequencies) {
-		frequencies = frequencies[:limit]
-	}
-
-	return frequencies, nil
+// defaultSkipDirs is the set of directories to skip when walking a tree
+var defaultSkipDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+	".idea":        true,
+	".vscode":      true,
+	"target":       true,
+	"build":        true,
+	"dist":         true,
+	"bin":          true,
+	"obj":          true,
 }
 
-func countLines(r io.Reader) int {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
-
-	lc := 0
-	for scanner.Scan() {
-		lc++
-	}
-
-	return lc
-}
-
-func countChars(r io.Reader) int {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanRunes)
-
-	cc := 0
-	for scanner.Scan() {
-		cc++
-	}
-
-	return cc
-}
-
-// detectLanguage tries to detect the language of the text
-// and returns the language tag (e.g., en-US, es, fr) and a human-readable name
-func detectLanguage(r io.Reader) (string, string, error) {
-	// We need to read the text into memory to process it
-	var buf bytes.Buffer
-	tee := io.TeeReader(r, &buf)
-	
-	// Read all the text (up to a reasonable limit)
-	// This gives better accuracy than just a small sample
-	scanner := bufio.NewScanner(tee)
-	scanner.Split(bufio.ScanWords)
-	
-	var sample strings.Builder
-	wordCount := 0
-	const maxWords = 1000 // Reasonable limit to avoid memory issues with very large files
-	
-	for scanner.Scan() && wordCount < maxWords {
-		if wordCount > 0 {
-			sample.WriteString(" ")
-		}
-		sample.WriteString(scanner.Text())
-		wordCount++
+// commentRatio returns the ratio of comment lines to code lines,
+// or 0 when there are no code lines to avoid dividing by zero
+func commentRatio(stats analyze.CodeStats) float64 {
+	if stats.Code == 0 {
+		return 0
 	}
-	
-	if err := scanner.Err(); err != nil {
-		return "", "", fmt.Errorf("error reading text: %w", err)
-	}
-	
-	// If we didn't get any words, we can't detect the language
-	if wordCount == 0 {
-		return "und", "Unknown", nil
-	}
-	
-	// Use whatlanggo for accurate language detection
-	text := sample.String()
-	// No special options needed - the default algorithm is already quite good
-	info := whatlanggo.Detect(text)
-	
-	// Get the ISO language code
-	langTag := info.Lang.Iso6391()
-	
-	// Get the English name of the language
-	langName := info.Lang.String()
-	
-	// If the language is unknown, fall back to a sensible default
-	if langTag == "" {
-		return "und", "Unknown", nil
-	}
-	
-	// For certain languages with common regional variants, add region code
-	// This is just an example - in a real system this would be more sophisticated
-	switch langTag {
-	case "en":
-		// For demo purposes, we'll mark English as US English
-		// A more sophisticated implementation might infer the region from the text
-		langTag = "en-US"
-		langName = "English (US)"
-	case "es":
-		// For demo purposes, we'll mark Spanish as Spanish from Spain
-		langTag = "es-ES"
-		langName = "Spanish (Spain)"
-	case "pt":
-		// For demo purposes, we'll mark Portuguese as Brazilian Portuguese
-		langTag = "pt-BR"
-		langName = "Portuguese (Brazil)"
-	case "zh":
-		// For demo purposes, we'll mark Chinese as Simplified Chinese
-		langTag = "zh-CN"
-		langName = "Chinese (Simplified)"
-	}
-	
-	return langTag, langName, nil
-}
-
-// CodeStats holds statistics about code in a file or directory
-type CodeStats struct {
-	Total     int // Total lines
-	Code      int // Lines of code (non-blank, non-comment)
-	Comments  int // Comment lines
-	Blank     int // Blank lines
-	Files     int // Number of files processed
+	return float64(stats.Comments) / float64(stats.Code)
 }
 
 // countLinesOfCode counts lines of code in files or directories without external dependencies
-func countLinesOfCode(paths []string) error {
-	// Set of directories to skip
-	skipDirs := map[string]bool{
-		".git":         true,
-		".hg":          true,
-		".svn":         true,
-		"node_modules": true,
-		".idea":        true,
-		".vscode":      true,
-		"target":       true,
-		"build":        true,
-		"dist":         true,
-		"bin":          true,
-		"obj":          true,
-	}
+func countLinesOfCode(cfg *Config) error {
+	skipDirs := defaultSkipDirs
 
 	// Set of file extensions to consider as code
 	codeExtensions := map[string]bool{
@@ -252,13 +81,23 @@ func countLinesOfCode(paths []string) error {
 		".kts":   true,
 		".ex":    true,
 		".exs":   true,
+		".lua":   true,
 		".md":    true,
 	}
 
+	// Add the user's extensions, or use only those if asked to
+	if cfg.OnlyExtensions {
+		codeExtensions = make(map[string]bool)
+	}
+	for _, ext := range cfg.ExtraExtensions {
+		codeExtensions[ext] = true
+	}
+
 	// Initialize statistics
-	stats := CodeStats{}
+	stats := analyze.CodeStats{}
 
 	// If no paths provided, use current directory
+	paths := cfg.Paths
 	if len(paths) == 0 {
 		paths = []string{"."}
 	}
@@ -272,42 +111,189 @@ func countLinesOfCode(paths []string) error {
 
 		if fileInfo.IsDir() {
 			// Process directory recursively
-			err = processDirectory(path, skipDirs, codeExtensions, &stats)
+			err = processDirectory(path, skipDirs, codeExtensions, &stats, cfg)
 			if err != nil {
 				return err
 			}
 		} else {
-			// Process single file
-			fileStats, err := processFile(path)
-			if err != nil {
-				return err
+			// Skip excluded and generated files if requested
+			if isExcluded(path, cfg.ExcludePatterns) || (cfg.NoGenerated && isGeneratedFile(path)) {
+				continue
 			}
 			
 			// Only count it if it has a recognized extension
 			ext := strings.ToLower(path[strings.LastIndexByte(path, '.')+1:])
-			if _, ok := codeExtensions["."+ext]; ok || len(ext) == 0 || ext == path {
-				stats.Total += fileStats.Total
-				stats.Code += fileStats.Code
-				stats.Comments += fileStats.Comments
-				stats.Blank += fileStats.Blank
-				stats.Files++
+			if _, ok := codeExtensions["."+ext]; !ok && len(ext) != 0 && ext != path {
+				continue
+			}
+			if cfg.ListFiles {
+				fmt.Fprintln(cfg.Output, path)
+				continue
+			}
+			
+			// Process single file
+			fileStats, err := processFile(path)
+			if err != nil {
+				return err
 			}
+			stats.Total += fileStats.Total
+			stats.Code += fileStats.Code
+			stats.Comments += fileStats.Comments
+			stats.Blank += fileStats.Blank
+			stats.Files++
 		}
 	}
 
-	// Print the code count
-	fmt.Println(stats.Code)
+	// Listing files replaces the counts entirely
+	if cfg.ListFiles {
+		return nil
+	}
+
+	// Print the full breakdown if requested, otherwise just the code count
+	if cfg.LOCVerbose {
+		fmt.Fprintf(cfg.Output, "Total:    %d\n", stats.Total)
+		fmt.Fprintf(cfg.Output, "Code:     %d\n", stats.Code)
+		fmt.Fprintf(cfg.Output, "Comments: %d\n", stats.Comments)
+		fmt.Fprintf(cfg.Output, "Blank:    %d\n", stats.Blank)
+		fmt.Fprintf(cfg.Output, "Files:    %d\n", stats.Files)
+	} else {
+		fmt.Fprintln(cfg.Output, stats.Code)
+	}
+
+	// Print the comment-to-code ratio if requested
+	if cfg.CommentRatio {
+		fmt.Fprintf(cfg.Output, "Comment ratio: %.2f\n", commentRatio(stats))
+	}
 	
 	return nil
 }
 
+// expandDirectories returns cfg.Paths with each directory replaced by the files
+// under it, walked with the same rules as --loc, when cfg.Recursive is set.
+// Without it a directory is an error rather than a confusing read failure.
+func expandDirectories(cfg *Config) ([]string, error) {
+	var paths []string
+	for _, path := range cfg.Paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// Missing files are reported when they're opened, and files named
+			// on the command line are counted even if binary unless --skip-binary
+			// says otherwise
+			if err == nil && cfg.SkipBinary && skipBinaryFile(path, cfg) {
+				continue
+			}
+			paths = append(paths, path)
+			continue
+		}
+		if !cfg.Recursive {
+			return nil, fmt.Errorf("%s is a directory (use -R to count the files in it)", path)
+		}
+
+		// Binary files found in a directory are always skipped, as their
+		// counts would be meaningless
+		err = walkDirectory(filepath.Clean(path), defaultSkipDirs, cfg, func(entryPath string) error {
+			if !isExcluded(entryPath, cfg.ExcludePatterns) && !skipBinaryFile(entryPath, cfg) {
+				paths = append(paths, entryPath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
 // processDirectory processes a directory recursively
-func processDirectory(dirPath string, skipDirs map[string]bool, codeExtensions map[string]bool, stats *CodeStats) error {
+func processDirectory(dirPath string, skipDirs map[string]bool, codeExtensions map[string]bool, stats *analyze.CodeStats, cfg *Config) error {
+	return walkDirectory(dirPath, skipDirs, cfg, func(entryPath string) error {
+		// Check if it's a code file based on extension
+		entryName := filepath.Base(entryPath)
+		ext := strings.ToLower(entryName[strings.LastIndexByte(entryName, '.')+1:])
+		if _, ok := codeExtensions["."+ext]; !ok {
+			return nil
+		}
+		
+		// Skip excluded and generated files if requested
+		if isExcluded(entryPath, cfg.ExcludePatterns) || (cfg.NoGenerated && isGeneratedFile(entryPath)) {
+			return nil
+		}
+
+		// Just name the file when listing instead of counting
+		if cfg.ListFiles {
+			fmt.Fprintln(cfg.Output, entryPath)
+			return nil
+		}
+
+		// Process code file
+		fileStats, err := processFile(entryPath)
+		if err != nil {
+			// Just skip problematic files
+			return nil
+		}
+
+		stats.Total += fileStats.Total
+		stats.Code += fileStats.Code
+		stats.Comments += fileStats.Comments
+		stats.Blank += fileStats.Blank
+		stats.Files++
+		return nil
+	})
+}
+
+// walkDirectory recursively calls visit for each file under dirPath,
+// skipping hidden entries and directories in skipDirs. Symlinked files are
+// visited like any other, but symlinked directories are only entered with
+// cfg.FollowSymlinks.
+func walkDirectory(dirPath string, skipDirs map[string]bool, cfg *Config, visit func(path string) error) error {
+	return walkDirectoryIgnoring(dirPath, skipDirs, nil, &visitedDirs{}, cfg, visit)
+}
+
+// visitedDirs records the directories a walk has entered. They're compared
+// with os.SameFile, which matches device and inode numbers on Unix, so a
+// directory reached again through a symlink is recognized.
+type visitedDirs []os.FileInfo
+
+// seen reports whether dir was entered before, recording it if not
+func (v *visitedDirs) seen(dir os.FileInfo) bool {
+	for _, info := range *v {
+		if os.SameFile(info, dir) {
+			return true
+		}
+	}
+	*v = append(*v, dir)
+	return false
+}
+
+// walkDirectoryIgnoring is walkDirectory with the .gitignore rules inherited
+// from parent directories, used when cfg.RespectGitignore is set
+func walkDirectoryIgnoring(dirPath string, skipDirs map[string]bool, ignores []gitignorePattern, visited *visitedDirs, cfg *Config, visit func(path string) error) error {
+	// When following symlinks, never enter a directory twice so that a link
+	// back to a parent can't send the walk round in a loop
+	if cfg.FollowSymlinks {
+		info, err := os.Stat(dirPath)
+		if err != nil {
+			return fmt.Errorf("failed to get file info for %s: %w", dirPath, err)
+		}
+		if visited.seen(info) {
+			return nil
+		}
+	}
+
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return fmt.Errorf("failed to read directory %s: %w", dirPath, err)
 	}
 
+	// Add this directory's .gitignore rules to those of its parents
+	if cfg.RespectGitignore {
+		patterns, err := readGitignore(dirPath)
+		if err != nil {
+			return err
+		}
+		ignores = append(ignores[:len(ignores):len(ignores)], patterns...)
+	}
+
 	for _, entry := range entries {
 		entryName := entry.Name()
 		entryPath := dirPath + "/" + entryName
@@ -317,199 +303,684 @@ func processDirectory(dirPath string, skipDirs map[string]bool, codeExtensions m
 			continue
 		}
 
-		if entry.IsDir() {
+		// Resolve symlinks to find out whether they lead to a directory
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			target, err := os.Stat(entryPath)
+			if err != nil {
+				// Skip broken links
+				continue
+			}
+			if target.IsDir() && !cfg.FollowSymlinks {
+				continue
+			}
+			isDir = target.IsDir()
+		}
+
+		// Skip anything matched by .gitignore
+		if isIgnored(ignores, entryPath, isDir) {
+			continue
+		}
+
+		if isDir {
 			// Skip directories in the ignore list
 			if skipDirs[entryName] {
 				continue
 			}
 
 			// Process subdirectory recursively
-			err = processDirectory(entryPath, skipDirs, codeExtensions, stats)
+			err = walkDirectoryIgnoring(entryPath, skipDirs, ignores, visited, cfg, visit)
 			if err != nil {
 				return err
 			}
 		} else {
-			// Check if it's a code file based on extension
-			ext := strings.ToLower(entryName[strings.LastIndexByte(entryName, '.')+1:])
-			if _, ok := codeExtensions["."+ext]; !ok {
+			// Skip files last changed before --since; directories are walked
+			// whatever their own age
+			if cfg.Since > 0 && !modifiedWithin(entry, entryPath, cfg.Since) {
 				continue
 			}
-
-			// Process code file
-			fileStats, err := processFile(entryPath)
-			if err != nil {
-				// Just skip problematic files
-				continue
+			
+			if err := visit(entryPath); err != nil {
+				return err
 			}
-
-			stats.Total += fileStats.Total
-			stats.Code += fileStats.Code
-			stats.Comments += fileStats.Comments
-			stats.Blank += fileStats.Blank
-			stats.Files++
 		}
 	}
 
 	return nil
 }
 
-// processFile counts lines of code, comments, and blank lines in a single file
-func processFile(filePath string) (CodeStats, error) {
-	stats := CodeStats{}
+// modifiedWithin reports whether the file at entryPath was modified less than
+// d ago, going by the target's modification time for a symlink
+func modifiedWithin(entry os.DirEntry, entryPath string, d time.Duration) bool {
+	info, err := entry.Info()
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err = os.Stat(entryPath)
+	}
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) < d
+}
+
+// parseSince parses a --since duration, which is anything time.ParseDuration
+// accepts or a number of days such as 7d
+func parseSince(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// isExcluded reports whether a file matches any of the --exclude patterns.
+// Patterns without a slash match the base name, so "*_test.go" excludes test
+// files at any depth. Patterns with a slash match the same number of trailing
+// path components, so "generated/*" excludes files directly inside any
+// directory named generated.
+func isExcluded(filePath string, patterns []string) bool {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(filePath)), "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+		n := strings.Count(pattern, "/") + 1
+		if n > len(parts) {
+			continue
+		}
+		tail := strings.Join(parts[len(parts)-n:], "/")
+		if matched, _ := filepath.Match(pattern, tail); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedFilePatterns are filename patterns that indicate generated code
+var generatedFilePatterns = []string{
+	"*.pb.go",
+	"*_generated.go",
+	"*.gen.go",
+}
+
+// generatedHeaderLines is how many leading lines are checked for a generated marker
+const generatedHeaderLines = 5
+
+// binarySniffLen is how much of a file is checked for a NUL byte, like git does,
+// to decide whether it's binary
+const binarySniffLen = 8192
+
+// isBinary reports whether the file at path looks binary, having a NUL byte
+// in its first binarySniffLen bytes
+func isBinary(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
+
+// skipBinaryFile reports whether path is a binary file to leave out, noting it on
+// cfg.ErrorOutput. Files that can't be read are kept so opening them reports
+// the error.
+func skipBinaryFile(path string, cfg *Config) bool {
+	if binary, err := isBinary(path); err != nil || !binary {
+		return false
+	}
+	fmt.Fprintf(cfg.ErrorOutput, "Skipping binary file %s\n", path)
+	return true
+}
+
+// isGeneratedFile reports whether a file looks machine-generated, either by its
+// name or by a "Code generated ... DO NOT EDIT." marker near the top of the file
+func isGeneratedFile(filePath string) bool {
+	// Check the filename against known generated patterns
+	base := filepath.Base(filePath)
+	for _, pattern := range generatedFilePatterns {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		return stats, fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return false
 	}
 	defer file.Close()
 
+	// Look for the standard marker in the first few lines
 	scanner := bufio.NewScanner(file)
-	isMultilineComment := false
-	
-	// Get file extension to determine comment syntax
-	ext := strings.ToLower(filePath[strings.LastIndexByte(filePath, '.')+1:])
-	
-	// This is a simplified approach - in a full implementation, you'd want
-	// a more robust language detection mechanism
-	for scanner.Scan() {
+	for i := 0; i < generatedHeaderLines && scanner.Scan(); i++ {
 		line := scanner.Text()
-		stats.Total++
-		
-		// Trimmed line for blank line detection
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine == "" {
-			stats.Blank++
-			continue
-		}
-		
-		// Detect comments based on file extension
-		// This is a simplified approach - a real implementation would be more thorough
-		switch ext {
-		case "go", "c", "cpp", "java", "js", "ts", "cs", "swift", "kt":
-			// Handle C-style comments
-			if isMultilineComment {
-				stats.Comments++
-				if strings.Contains(line, "*/") {
-					isMultilineComment = false
-				}
-				continue
-			}
-			
-			if strings.HasPrefix(trimmedLine, "//") {
-				stats.Comments++
-				continue
-			}
-			
-			if strings.HasPrefix(trimmedLine, "/*") {
-				isMultilineComment = true
-				stats.Comments++
-				if strings.Contains(line, "*/") {
-					isMultilineComment = false
-				}
-				continue
-			}
-			
-		case "py", "rb":
-			// Handle Python/Ruby style comments
-			if strings.HasPrefix(trimmedLine, "#") {
-				stats.Comments++
-				continue
-			}
-			
-		case "sh", "bash":
-			// Handle shell script comments
-			if strings.HasPrefix(trimmedLine, "#") {
-				stats.Comments++
-				continue
-			}
-			
-		// Add more languages as needed
+		if strings.Contains(line, "Code generated") && strings.Contains(line, "DO NOT EDIT") {
+			return true
 		}
-		
-		// If not a comment or blank line, count as code
-		stats.Code++
 	}
 
-	if err := scanner.Err(); err != nil {
+	return false
+}
+
+// processFile counts lines of code, comments, and blank lines in a single file
+func processFile(filePath string) (analyze.CodeStats, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return analyze.CodeStats{}, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer file.Close()
+	
+	// Get file extension to determine comment syntax
+	ext := strings.ToLower(filePath[strings.LastIndexByte(filePath, '.')+1:])
+	
+	stats, err := analyze.CountCode(file, ext)
+	if err != nil {
 		return stats, fmt.Errorf("error reading file %s: %w", filePath, err)
 	}
 
 	return stats, nil
 }
 
-// Config holds the configuration for the program
-type Config struct {
-	LOC                bool
-	Line               bool
-	Char               bool
-	Word               bool
-	DetectLanguage     bool
-	ShowLanguageName   bool
-	FrequencyAnalysis  bool
-	FrequencyLimit     int
-	SortByCount        bool
-	Paths              []string
-	Input              io.Reader
-	Output             io.Writer
-	ErrorOutput        io.Writer
+// FileStats holds the full set of text statistics for a single file
+type FileStats struct {
+	Lines       int    `json:"lines"`
+	Words       int    `json:"words"`
+	Chars       int    `json:"chars"`
+	Bytes       int    `json:"bytes"`
+	Sentences   int    `json:"sentences"`
+	UniqueWords int    `json:"unique_words"`
+	Language    string `json:"language"`
 }
 
-// NewDefaultConfig creates a default configuration
-func NewDefaultConfig() *Config {
-	return &Config{
-		Input:          os.Stdin,
-		Output:         os.Stdout,
-		ErrorOutput:    os.Stderr,
-		FrequencyLimit: 10, // Default to showing top 10 words
+// collectFileStats computes every text statistic for a single file
+func collectFileStats(path string, cfg *Config) (FileStats, error) {
+	file, err := openPath(path)
+	if err != nil {
+		return FileStats{}, err
 	}
-}
-
-// ParseFlags parses command-line flags and updates the configuration
-func ParseFlags(cfg *Config) {
-	// Check for help flag manually
-	for _, arg := range os.Args[1:] {
-		if arg == "-h" || arg == "--help" {
-			fmt.Fprintf(cfg.ErrorOutput, "Usage: %s [flags] [path...]\n\n", os.Args[0])
-			fmt.Fprintf(cfg.ErrorOutput, "Text and code analysis utility for counting, language detection, and more.\n")
-			fmt.Fprintf(cfg.ErrorOutput, "By default, counts words from stdin.\n\n")
-			fmt.Fprintf(cfg.ErrorOutput, "Options:\n")
-			fmt.Fprintf(cfg.ErrorOutput, "  -w, --words       Count words (default behavior)\n")
-			fmt.Fprintf(cfg.ErrorOutput, "  -l, --lines       Count lines instead of words\n")
-			fmt.Fprintf(cfg.ErrorOutput, "  -c, --chars       Count characters instead of words\n")
-			fmt.Fprintf(cfg.ErrorOutput, "      --loc         Count lines of code in specified paths or current directory\n")
-			fmt.Fprintf(cfg.ErrorOutput, "      --lang        Detect language of text in specified files or stdin\n")
-			fmt.Fprintf(cfg.ErrorOutput, "      --lang-name   Show human-readable language name (implies --lang)\n")
-			fmt.Fprintf(cfg.ErrorOutput, "      --freq        Analyze word frequency\n")
-			fmt.Fprintf(cfg.ErrorOutput, "      --sort-count  Sort frequency by count (default is alphabetical)\n")
-			fmt.Fprintf(cfg.ErrorOutput, "      --limit N     Limit frequency results to top N words\n")
-			fmt.Fprintf(cfg.ErrorOutput, "  -h, --help        Show this help message\n")
-			os.Exit(0)
-		}
+	defer file.Close()
+	
+	// Read the file contents to handle multiple passes
+	contents, err := io.ReadAll(prepareReader(file, cfg))
+	if err != nil {
+		return FileStats{}, fmt.Errorf("failed to read file %s: %w", path, err)
 	}
 	
-	// Define flags
-	var loc bool
-	var l, c, w bool
-	var lang, langName bool
-	var freq, sortByCount bool
-	var limit int
+	langTag, _, _, err := analyze.DetectLanguage(bytes.NewReader(contents), languageOptions(cfg))
+	if err != nil {
+		return FileStats{}, fmt.Errorf("failed to detect language for %s: %w", path, err)
+	}
+	
+	return FileStats{
+		Lines:       analyze.CountLines(bytes.NewReader(contents)),
+		Words:       countWords(bytes.NewReader(contents), cfg),
+		Chars:       countChars(bytes.NewReader(contents), cfg),
+		Bytes:       len(contents),
+		Sentences:   analyze.CountSentences(bytes.NewReader(contents)),
+		UniqueWords: analyze.CountUniqueWords(bytes.NewReader(contents), wordOptions(cfg)),
+		Language:    langTag,
+	}, nil
+}
+
+// writeManifest walks the configured paths and writes a JSON manifest
+// mapping each file to its full statistics
+func writeManifest(cfg *Config) error {
+	// If no paths provided, use current directory
+	paths := cfg.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	
+	manifest := make(map[string]FileStats)
+	addFile := func(path string) error {
+		stats, err := collectFileStats(path, cfg)
+		if err != nil {
+			return err
+		}
+		manifest[path] = stats
+		return nil
+	}
+	
+	for _, path := range paths {
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to get file info for %s: %w", path, err)
+		}
+		
+		if fileInfo.IsDir() {
+			err = walkDirectory(path, defaultSkipDirs, cfg, addFile)
+		} else {
+			err = addFile(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	
+	if err := os.WriteFile(cfg.ManifestPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", cfg.ManifestPath, err)
+	}
+	
+	return nil
+}
+
+// Config holds the configuration for the program
+type Config struct {
+	LOC                 bool
+	LOCVerbose          bool
+	ListFiles           bool
+	CommentRatio        bool
+	NoGenerated         bool
+	SkipBinary          bool
+	RespectGitignore    bool
+	FollowSymlinks      bool
+	Since               time.Duration
+	Recursive           bool
+	ExcludePatterns     []string
+	ExtraExtensions     []string
+	OnlyExtensions      bool
+	ManifestPath        string
+	Line                bool
+	Char                bool
+	Byte                bool
+	Sentence            bool
+	UniqueWords         bool
+	MaxLineLength       bool
+	Head                int
+	Tail                int
+	AvgLineLength       bool
+	Stats               bool
+	Readability         bool
+	Density             bool
+	Summary             bool
+	TTR                 bool
+	Entropy             bool
+	EntropyBytes        bool
+	CapsStats           bool
+	LengthHistogram     bool
+	LineLengthHistogram bool
+	BucketSize          int
+	Word                bool
+	DetectLanguage      bool
+	ShowLanguageName    bool
+	LangConfidence      bool
+	LangCandidates      int
+	ShowScript          bool
+	Locales             map[string]string
+	LangMinWords        int
+	LangMinLength       int
+	LangMinConfidence   float64
+	LangHints           []string
+	FrequencyAnalysis   bool
+	FrequencyLimit      int
+	CharFrequency       bool
+	CharWhitespace      bool
+	TopChars            int
+	ShowPercent         bool
+	Cloud               bool
+	WithPosition        bool
+	MinCount            int
+	NgramSize           int
+	SuffixLen           int
+	PrefixLen           int
+	MaxColWidth         int
+	SortMode            string
+	Reverse             bool
+	Combined            bool
+	LangSummary         bool
+	REPL                bool
+	FilesFrom           string
+	FilesFromLines      bool // FilesFrom lists one path per line instead of NUL-separated
+	Diff                bool
+	FilterStopwords     bool
+	CaseSensitive       bool
+	TrimChars           string
+	WordMode            string
+	CJKMode             bool
+	Distinct            bool
+	TabWidth            int
+	NoTrim              bool
+	Concat              bool
+	Sum                 bool
+	DictPath            string
+	CountWord           string
+	GrepPattern         string
+	GrepRegexp          *regexp.Regexp
+	GrepInvert          bool
+	Tokens              bool
+	DupLines            bool
+	DupBlank            bool
+	LineFrequency       bool
+	TrimLines           bool
+	Anagrams            bool
+	StripFrontMatter    bool
+	SampleBytes         int
+	SampleLines         int
+	InputEncoding       string
+	Normalize           bool
+	BOMReport           bool
+	LineEndings         bool
+	WhitespaceReport    bool
+	CSVOutput           bool
+	TSVOutput           bool
+	NDJSON              bool
+	JSONOutPath         string
+	Follow              bool
+	FollowInterval      time.Duration
+	OutputPath          string
+	Quiet               bool
+	NoHeader            bool
+	Human               bool
+	Timing              bool
+	KeepGoing           bool
+	HashAlgo            string
+	Paths               []string
+	Input               io.Reader
+	Output              io.Writer
+	ErrorOutput         io.Writer
+	DefaultArgs         [][]string // Flags from .lexorc, each with its value, parsed before the command line
+}
+
+// NewDefaultConfig creates a default configuration
+func NewDefaultConfig() *Config {
+	return &Config{
+		Input:          os.Stdin,
+		Output:         os.Stdout,
+		ErrorOutput:    os.Stderr,
+		FrequencyLimit: 10, // Default to showing top 10 words
+		NgramSize:      1,  // Default to single words
+	}
+}
+
+// printUsage writes the usage message listing every flag to w
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [flags] [path...]\n\n", os.Args[0])
+	fmt.Fprintf(w, "Text and code analysis utility for counting, language detection, and more.\n")
+	fmt.Fprintf(w, "By default, counts words from stdin.\n\n")
+	fmt.Fprintf(w, "Options:\n")
+	fmt.Fprintf(w, "  -w, --words       Count words (default behavior)\n")
+	fmt.Fprintf(w, "  -l, --lines       Count lines instead of words\n")
+	fmt.Fprintf(w, "  -c, --chars       Count characters (Unicode runes) instead of words\n")
+	fmt.Fprintf(w, "  -b, --bytes       Count bytes instead of words (like wc -c)\n")
+	fmt.Fprintf(w, "      --sentences   Count sentences instead of words\n")
+	fmt.Fprintf(w, "      --unique      Count distinct words (shown above the table with --freq)\n")
+	fmt.Fprintf(w, "      --distinct    With -w, count distinct words normalized as for --freq (so --case-sensitive applies)\n")
+	fmt.Fprintf(w, "  -L, --max-line-length  Show the width of the longest line (tabs stop every 8 columns)\n")
+	fmt.Fprintf(w, "      --tab-width N  Put tab stops every N columns for -L, and count tabs as the columns they span for -c\n")
+	fmt.Fprintf(w, "      --avg-line-length  Show the mean characters per line, not counting line endings\n")
+	fmt.Fprintf(w, "      --stats       Show average word length and the longest and shortest words\n")
+	fmt.Fprintf(w, "      --readability  Show the Flesch Reading Ease score (approximate)\n")
+	fmt.Fprintf(w, "      --summary     Show lines, words, characters, bytes, sentences and paragraphs together\n")
+	fmt.Fprintf(w, "      --ttr         Show the type-token ratio: distinct words divided by all words\n")
+	fmt.Fprintf(w, "      --entropy     Show the Shannon entropy in bits per character\n")
+	fmt.Fprintf(w, "      --entropy-bytes  Show the Shannon entropy in bits per byte (8 for random data)\n")
+	fmt.Fprintf(w, "      --density     Show average words per sentence and per paragraph\n")
+	fmt.Fprintf(w, "      --caps-stats  Show how many words are ALL-CAPS, Capitalized and lowercase\n")
+	fmt.Fprintf(w, "      --length-histogram  Show how many words there are of each length\n")
+	fmt.Fprintf(w, "      --line-length-histogram  Show how many lines there are in each range of lengths, in characters\n")
+	fmt.Fprintf(w, "      --bucket-size N  Make each --line-length-histogram range N characters wide (default 10)\n")
+	fmt.Fprintf(w, "      --loc         Count lines of code in specified paths or current directory\n")
+	fmt.Fprintf(w, "      --loc-verbose  Also report total, comment and blank lines and files (implies --loc)\n")
+	fmt.Fprintf(w, "      --list-files  Print the files --loc would count instead of counting them (implies --loc)\n")
+	fmt.Fprintf(w, "      --comment-ratio  Also print the comment-to-code ratio (implies --loc)\n")
+	fmt.Fprintf(w, "      --no-generated  Skip generated files (DO NOT EDIT headers, *.pb.go) when counting code\n")
+	fmt.Fprintf(w, "      --respect-gitignore  Skip files matched by .gitignore when scanning directories\n")
+	fmt.Fprintf(w, "  -R, --recursive   Analyze the files under directory arguments, skipping those --loc skips and binary files\n")
+	fmt.Fprintf(w, "      --skip-binary  Also skip binary files (with a NUL byte in the first 8KB) named on the command line\n")
+	fmt.Fprintf(w, "      --follow-symlinks  Descend into symlinked directories when scanning (each directory once)\n")
+	fmt.Fprintf(w, "      --since DURATION  Skip files not modified within DURATION, e.g. 24h or 7d\n")
+	fmt.Fprintf(w, "      --exclude PATTERN  Skip code files matching PATTERN (repeatable)\n")
+	fmt.Fprintf(w, "      --ext LIST    Also count code files with these comma-separated extensions\n")
+	fmt.Fprintf(w, "      --only-ext LIST  Count only code files with these comma-separated extensions\n")
+	fmt.Fprintf(w, "      --manifest FILE  Walk paths and write per-file stats as JSON to FILE\n")
+	fmt.Fprintf(w, "      --lang        Detect language of text in specified files or stdin\n")
+	fmt.Fprintf(w, "      --lang-name   Show human-readable language name (implies --lang)\n")
+	fmt.Fprintf(w, "      --lang-confidence  Show the detector's confidence from 0 to 1 (implies --lang)\n")
+	fmt.Fprintf(w, "      --lang-candidates N  Rank the N most likely languages (implies --lang)\n")
+	fmt.Fprintf(w, "      --script      Also show the writing script, e.g. Latin or Cyrillic (implies --lang)\n")
+	fmt.Fprintf(w, "      --lang-summary  Also count the files in each language (implies --lang; -q hides per-file results)\n")
+	fmt.Fprintf(w, "      --lang-hint LIST  Only consider these languages, e.g. en,es,fr (helps with short text)\n")
+	fmt.Fprintf(w, "      --locale LIST  Report languages with these regions, e.g. en=en-GB,pt=pt-PT\n")
+	fmt.Fprintf(w, "      --lang-min-words N  Report und/Unknown for inputs with fewer than N words\n")
+	fmt.Fprintf(w, "      --lang-min-confidence C  Report und/Unknown when the confidence in the best guess is below C (0 to 1)\n")
+	fmt.Fprintf(w, "      --lang-min-length N  Warn that the language is unreliable for inputs under N characters\n")
+	fmt.Fprintf(w, "      --freq        Analyze word frequency\n")
+	fmt.Fprintf(w, "      --char-freq   Analyze character frequency\n")
+	fmt.Fprintf(w, "      --char-whitespace  Include whitespace in character frequency\n")
+	fmt.Fprintf(w, "      --top-chars N  Show the N most common characters with their code points\n")
+	fmt.Fprintf(w, "      --include-spaces  Same as --char-whitespace\n")
+	fmt.Fprintf(w, "      --sort MODE   Sort frequency by alpha (default), count or length (longest first)\n")
+	fmt.Fprintf(w, "      --sort-count  Sort frequency by count (same as --sort count)\n")
+	fmt.Fprintf(w, "  -r, --reverse     Reverse the frequency sort, e.g. least frequent first\n")
+	fmt.Fprintf(w, "      --case-sensitive  Count words differing only in case separately\n")
+	fmt.Fprintf(w, "      --trim-chars STRING  Trim these characters from word ends instead of .,;:!?\"'()[]{}\n")
+	fmt.Fprintf(w, "      --word-mode MODE  Split words on whitespace (default), or keep only runs of letters (alpha) or letters and digits (alnum)\n")
+	fmt.Fprintf(w, "      --cjk         Count each Chinese or Japanese (Han, Hiragana, Katakana) character as a word\n")
+	fmt.Fprintf(w, "      --no-trim     Count words verbatim, without trimming punctuation\n")
+	fmt.Fprintf(w, "      --no-stopwords  Exclude common words from frequency (English only)\n")
+	fmt.Fprintf(w, "      --head N      Only show the counts of the first N files (the total still covers every file)\n")
+	fmt.Fprintf(w, "      --tail N      Only show the counts of the last N files (the total still covers every file)\n")
+	fmt.Fprintf(w, "      --limit N     Limit frequency results to top N words\n")
+	fmt.Fprintf(w, "      --min-count N  Only show words appearing at least N times\n")
+	fmt.Fprintf(w, "      --percent     Show each word's share of all the words counted in frequency output\n")
+	fmt.Fprintf(w, "      --with-position  Add where each word first appears, counting words from 1 (implies --freq)\n")
+	fmt.Fprintf(w, "      --cloud       Print word:weight pairs for word clouds, weights scaled from 1 to 100 (implies --freq)\n")
+	fmt.Fprintf(w, "      --ngram N     Count sequences of N words instead of single words\n")
+	fmt.Fprintf(w, "      --suffix N    Count the last N characters of each word of at least N characters (implies --freq)\n")
+	fmt.Fprintf(w, "      --prefix N    Count the first N characters of each word of at least N characters (implies --freq)\n")
+	fmt.Fprintf(w, "      --max-col-width N  Truncate frequency words wider than N columns\n")
+	fmt.Fprintf(w, "      --concat      Analyze all files as one concatenated document\n")
+	fmt.Fprintf(w, "      --sum         Count stdin and all files as one stream with a single total\n")
+	fmt.Fprintf(w, "      --combined    Add up frequencies from all files into one table\n")
+	fmt.Fprintf(w, "      --dict FILE   List the words not in FILE, a newline-separated word list, with their counts\n")
+	fmt.Fprintf(w, "      --count-word WORD  Print how many times WORD appears, normalized as for --freq\n")
+	fmt.Fprintf(w, "      --grep REGEX  Count the lines matching REGEX, like grep -c\n")
+	fmt.Fprintf(w, "      --grep-invert  Count the lines not matching --grep instead, like grep -vc\n")
+	fmt.Fprintf(w, "      --tokens      Print each word, normalized as for --freq, on its own line in document order\n")
+	fmt.Fprintf(w, "      --dup-lines   List every line that appears more than once with its count, most repeated first (--limit N for the top N)\n")
+	fmt.Fprintf(w, "      --dup-blank   Count blank lines as duplicates with --dup-lines\n")
+	fmt.Fprintf(w, "      --line-freq   List the most frequent lines with their counts, like sort | uniq -c | sort -rn\n")
+	fmt.Fprintf(w, "      --trim-lines  Ignore whitespace around lines with --line-freq\n")
+	fmt.Fprintf(w, "      --anagrams    Group words made of the same letters, biggest groups first\n")
+	fmt.Fprintf(w, "      --diff A B    Show words whose counts differ between two files, largest change first\n")
+	fmt.Fprintf(w, "      --strip-frontmatter  Skip a leading ---...--- front matter block\n")
+	fmt.Fprintf(w, "      --sample N    Only analyze the first N bytes of each input, for a quick estimate\n")
+	fmt.Fprintf(w, "      --sample-lines N  Only analyze the first N lines of each input\n")
+	fmt.Fprintf(w, "      --encoding NAME  Convert input from NAME (latin1, windows1252, utf16le, utf16be) to UTF-8\n")
+	fmt.Fprintf(w, "      --normalize   Read smart quotes, dashes and non-breaking spaces as their ASCII equivalents\n")
+	fmt.Fprintf(w, "      --bom         Report which byte order mark (UTF-8, UTF-16LE, UTF-16BE) each input starts with\n")
+	fmt.Fprintf(w, "      --line-endings  Count each input's LF, CRLF and CR line endings, flagging a mix\n")
+	fmt.Fprintf(w, "      --whitespace-report  Count lines with trailing whitespace or tab and space indentation\n")
+	fmt.Fprintf(w, "      --csv         Write counts or frequencies as CSV with a header row\n")
+	fmt.Fprintf(w, "      --tsv         Write counts or frequencies as tab-separated values\n")
+	fmt.Fprintf(w, "      --ndjson      Write counts, frequencies or languages as one JSON object per line\n")
+	fmt.Fprintf(w, "      --json-out FILE  Also write counts, frequencies or languages as a JSON array to FILE\n")
+	fmt.Fprintf(w, "  -q, --quiet       Print bare numbers, and frequency rows without headers, for scripts\n")
+	fmt.Fprintf(w, "      --no-header   Leave off the frequency table headings and the \"Language:\" label, keeping the rows as they are\n")
+	fmt.Fprintf(w, "      --human       Shorten counts of 1000 or more, e.g. 1.2K and 1.5M\n")
+	fmt.Fprintf(w, "      --hash ALGO   Print a md5, sha1 or sha256 digest of each input's bytes alongside its counts\n")
+	fmt.Fprintf(w, "      --keep-going  Report unreadable files on stderr and carry on, exiting 1 at the end\n")
+	fmt.Fprintf(w, "      --timing      Print how long each file took, and the total, to stderr\n")
+	fmt.Fprintf(w, "      --output FILE  Write results to FILE instead of stdout\n")
+	fmt.Fprintf(w, "      --files-from FILE  Read paths from FILE (- for stdin), one per line, skipping blanks and # comments\n")
+	fmt.Fprintf(w, "      --files0-from FILE  Read NUL-separated paths from FILE (- for stdin), like find -print0\n")
+	fmt.Fprintf(w, "  -0, --null        Read NUL-separated paths from stdin (same as --files0-from -)\n")
+	fmt.Fprintf(w, "      --repl        Analyze stdin a line at a time, printing each line's result as it's read\n")
+	fmt.Fprintf(w, "  -f, --follow      Keep printing a file's counts as it grows, like tail -f\n")
+	fmt.Fprintf(w, "      --follow-interval DURATION  How often --follow checks the file (default 1s)\n")
+	fmt.Fprintf(w, "  -h, --help        Show this help message\n")
+}
+
+// ParseFlags parses command-line flags and updates the configuration.
+// It returns an error for arguments it can't make sense of.
+func ParseFlags(cfg *Config) error {
+	// Check for help flag manually
+	for _, arg := range os.Args[1:] {
+		if arg == "-h" || arg == "--help" {
+			printUsage(cfg.ErrorOutput)
+			os.Exit(0)
+		}
+	}
+	
+	// Defaults from the config file come first so the command line overrides
+	// them, leaving out any the command line sets, turns off or clashes with
+	cmdline, cmdlineSet := commandLineFlags(os.Args[1:])
+	return parseArgs(cfg, append(configArgs(cfg, cmdline, cmdlineSet), cmdline...))
+}
+
+// parseArgs parses args, the flags and paths from the config file and command
+// line together, into cfg
+func parseArgs(cfg *Config, args []string) error {
+	// Define flags
+	var loc, locVerbose, listFiles, commentRatio, noGenerated, skipBinary, respectGitignore, followSymlinks, recursive, onlyExt bool
+	var l, c, b, w, sentences, unique, maxLineLength, avgLineLength, stats, readability, density, summary, ttr, entropy, entropyBytes, capsStats, lengthHistogram, lineLengthHistogram bool
+	var lang, langName, langConfidence, script, langSummary bool
+	var freq, charFreq, showPercent, cloud, withPosition, cjk, distinct, grepInvert, charWhitespace, noStopwords, caseSensitive, noTrim, concat, sum, tokens, dupLines, dupBlank, lineFreq, trimLines, anagrams, stripFrontMatter, normalize, bomReport, lineEndings, whitespaceReport, csvOutput, tsvOutput, ndjson bool
+	var follow, quiet, noHeader, human, timing, keepGoing, reverse, combined, repl, diff, filesFromLines bool
+	var followInterval, since time.Duration
+	var langMinConfidence float64
+	var limit, head, tail, sampleBytes, sampleLines, topChars, minCount, langMinWords, langMinLength, langCandidates, maxColWidth, ngram, suffixLen, prefixLen, tabWidth, bucketSize int
+	var manifest, jsonOut, trimChars, output, sortMode, filesFrom, dictPath, countWord, grepPattern, hashAlgo, inputEncoding, wordMode string
+	var exclude, extensions []string
+	var locales map[string]string
+	var langHints []string
 	var paths []string
 	
 	// Process args to handle GNU-style long options
-	for i := 0; i < len(os.Args[1:]); i++ {
-		arg := os.Args[1:][i]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		
+		// Accept GNU wc's --files0-from=FILE spelling as well as a separate value
+		if strings.HasPrefix(arg, "--files0-from=") {
+			filesFrom = strings.TrimPrefix(arg, "--files0-from=")
+			filesFromLines = false
+			continue
+		}
+		if strings.HasPrefix(arg, "--files-from=") {
+			filesFrom = strings.TrimPrefix(arg, "--files-from=")
+			filesFromLines = true
+			continue
+		}
 		
 		// Process flags
 		switch arg {
 		case "--loc":
 			loc = true
 			continue
+		case "--loc-verbose":
+			loc = true
+			locVerbose = true
+			continue
+		case "--list-files":
+			loc = true
+			listFiles = true
+			continue
+		case "--comment-ratio":
+			loc = true
+			commentRatio = true
+			continue
+		case "--no-generated":
+			noGenerated = true
+			continue
+		case "--respect-gitignore":
+			respectGitignore = true
+			continue
+		case "--follow-symlinks":
+			followSymlinks = true
+			continue
+		case "-R", "--recursive":
+			recursive = true
+			continue
+		case "--skip-binary":
+			skipBinary = true
+			continue
+		case "--exclude":
+			var pattern string
+			if parseStringValue(args, &i, &pattern) {
+				exclude = append(exclude, pattern)
+			}
+			continue
+		case "--ext", "--only-ext":
+			var list string
+			if parseStringValue(args, &i, &list) {
+				extensions = append(extensions, parseExtensions(list)...)
+			}
+			if arg == "--only-ext" {
+				onlyExt = true
+			}
+			continue
+		case "--manifest":
+			parseStringValue(args, &i, &manifest)
+			continue
 		case "-l", "--lines":
 			l = true
 			continue
 		case "-c", "--chars":
 			c = true
 			continue
+		case "-b", "--bytes":
+			b = true
+			continue
+		case "--sentences":
+			sentences = true
+			continue
+		case "--unique":
+			unique = true
+			continue
+		case "--distinct":
+			distinct = true
+			continue
+		case "-L", "--max-line-length":
+			maxLineLength = true
+			continue
+		case "--avg-line-length":
+			avgLineLength = true
+			continue
+		case "--stats":
+			stats = true
+			continue
+		case "--readability":
+			readability = true
+			continue
+		case "--ttr":
+			ttr = true
+			continue
+		case "--entropy":
+			entropy = true
+			continue
+		case "--entropy-bytes":
+			entropyBytes = true
+			continue
+		case "--summary":
+			summary = true
+			continue
+		case "--density":
+			density = true
+			continue
+		case "--caps-stats":
+			capsStats = true
+			continue
+		case "--length-histogram":
+			lengthHistogram = true
+			continue
+		case "--line-length-histogram":
+			lineLengthHistogram = true
+			continue
+		case "--bucket-size":
+			if !parseIntValue(args, &i, &bucketSize) || bucketSize <= 0 {
+				return fmt.Errorf("invalid --bucket-size: want a positive number of characters")
+			}
+			continue
 		case "-w", "--words":
 			w = true
 			continue
@@ -520,47 +991,537 @@ func ParseFlags(cfg *Config) {
 			lang = true
 			langName = true
 			continue
+		case "--lang-confidence":
+			lang = true
+			langConfidence = true
+			continue
 		case "--freq":
 			freq = true
 			continue
+		case "--char-freq":
+			charFreq = true
+			continue
+		case "--char-whitespace", "--include-spaces":
+			charFreq = true
+			charWhitespace = true
+			continue
 		case "--sort-count":
-			sortByCount = true
+			sortMode = string(analyze.SortCount)
 			continue
-		case "--limit":
-			// Check if there's a next argument for the limit value
-			if i+1 < len(os.Args[1:]) {
-				// Try to parse the next argument as a number
-				if n, err := fmt.Sscanf(os.Args[1:][i+1], "%d", &limit); n == 1 && err == nil {
-					// Skip the next arg since we've consumed it
-					i++
-					continue
+		case "--top-chars":
+			if !parseIntValue(args, &i, &topChars) || topChars <= 0 {
+				return fmt.Errorf("invalid --top-chars: want a positive number of characters")
+			}
+			charFreq = true
+			sortMode = string(analyze.SortCount)
+			continue
+		case "--files0-from":
+			parseStringValue(args, &i, &filesFrom)
+			filesFromLines = false
+			continue
+		case "--files-from":
+			parseStringValue(args, &i, &filesFrom)
+			filesFromLines = true
+			continue
+		case "-0", "--null":
+			filesFrom = "-"
+			filesFromLines = false
+			continue
+		case "--repl":
+			repl = true
+			continue
+		case "--diff":
+			diff = true
+			continue
+		case "--combined":
+			combined = true
+			continue
+		case "-r", "--reverse":
+			reverse = true
+			continue
+		case "--sort":
+			if parseStringValue(args, &i, &sortMode) {
+				switch analyze.SortMode(sortMode) {
+				case analyze.SortAlpha, analyze.SortCount, analyze.SortLength:
+				default:
+					return fmt.Errorf("invalid --sort %q: want alpha, count or length", sortMode)
+				}
+			}
+			continue
+		case "--no-stopwords":
+			noStopwords = true
+			continue
+		case "--case-sensitive":
+			caseSensitive = true
+			continue
+		case "--trim-chars":
+			parseStringValue(args, &i, &trimChars)
+			continue
+		case "--no-trim":
+			noTrim = true
+			continue
+		case "--concat":
+			concat = true
+			continue
+		case "--sum":
+			sum = true
+			continue
+		case "--dict":
+			parseStringValue(args, &i, &dictPath)
+			continue
+		case "--count-word":
+			parseStringValue(args, &i, &countWord)
+			continue
+		case "--grep":
+			if !parseStringValue(args, &i, &grepPattern) {
+				return fmt.Errorf("invalid --grep: want a regular expression")
+			}
+			continue
+		case "--grep-invert":
+			grepInvert = true
+			continue
+		case "--word-mode":
+			parseStringValue(args, &i, &wordMode)
+			switch analyze.WordMode(wordMode) {
+			case analyze.WordsWhitespace, analyze.WordsAlpha, analyze.WordsAlnum:
+			default:
+				return fmt.Errorf("invalid --word-mode %q: want whitespace, alpha or alnum", wordMode)
+			}
+			continue
+		case "--cjk":
+			cjk = true
+			continue
+		case "--percent":
+			showPercent = true
+			continue
+		case "--cloud":
+			freq = true
+			cloud = true
+			continue
+		case "--with-position":
+			freq = true
+			withPosition = true
+			continue
+		case "--tokens":
+			tokens = true
+			continue
+		case "--anagrams":
+			anagrams = true
+			continue
+		case "--dup-lines":
+			dupLines = true
+			continue
+		case "--dup-blank":
+			dupBlank = true
+			continue
+		case "--line-freq":
+			lineFreq = true
+			continue
+		case "--trim-lines":
+			trimLines = true
+			continue
+		case "--strip-frontmatter":
+			stripFrontMatter = true
+			continue
+		case "--normalize":
+			normalize = true
+			continue
+		case "--line-endings":
+			lineEndings = true
+			continue
+		case "--whitespace-report":
+			whitespaceReport = true
+			continue
+		case "--bom":
+			bomReport = true
+			continue
+		case "--encoding":
+			var name string
+			parseStringValue(args, &i, &name)
+			inputEncoding = normalizeEncodingName(name)
+			if _, ok := inputEncodings[inputEncoding]; !ok {
+				return fmt.Errorf("invalid --encoding %q: want one of %s", name, inputEncodingNames())
+			}
+			continue
+		case "--csv":
+			csvOutput = true
+			continue
+		case "--tsv":
+			tsvOutput = true
+			continue
+		case "--ndjson":
+			ndjson = true
+			continue
+		case "--json-out":
+			parseStringValue(args, &i, &jsonOut)
+			continue
+		case "--human":
+			human = true
+			continue
+		case "--timing":
+			timing = true
+			continue
+		case "--keep-going":
+			keepGoing = true
+			continue
+		case "--hash":
+			parseStringValue(args, &i, &hashAlgo)
+			if _, ok := hashAlgorithms[hashAlgo]; !ok {
+				return fmt.Errorf("invalid --hash %q: want one of %s", hashAlgo, hashAlgorithmNames())
+			}
+			continue
+		case "-q", "--quiet":
+			quiet = true
+			continue
+		case "--no-header":
+			noHeader = true
+			continue
+		case "--output":
+			parseStringValue(args, &i, &output)
+			continue
+		case "-f", "--follow":
+			follow = true
+			continue
+		case "--since":
+			var value string
+			if parseStringValue(args, &i, &value) {
+				d, err := parseSince(value)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid --since %q: want a duration like 24h or 7d", value)
+				}
+				since = d
+			}
+			continue
+		case "--follow-interval":
+			var interval string
+			if parseStringValue(args, &i, &interval) {
+				d, err := time.ParseDuration(interval)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid --follow-interval %q: want a duration like 500ms or 2s", interval)
 				}
+				followInterval = d
 			}
+			continue
+		case "--sample":
+			parseIntValue(args, &i, &sampleBytes)
+			continue
+		case "--sample-lines":
+			parseIntValue(args, &i, &sampleLines)
+			continue
+		case "--head":
+			parseIntValue(args, &i, &head)
+			continue
+		case "--tail":
+			parseIntValue(args, &i, &tail)
+			continue
+		case "--limit":
 			// If we can't parse a number, use the default limit
+			parseIntValue(args, &i, &limit)
+			continue
+		case "--min-count":
+			parseIntValue(args, &i, &minCount)
+			continue
+		case "--lang-min-words":
+			parseIntValue(args, &i, &langMinWords)
+			continue
+		case "--lang-min-length":
+			parseIntValue(args, &i, &langMinLength)
+			continue
+		case "--lang-min-confidence":
+			var value string
+			if parseStringValue(args, &i, &value) {
+				c, err := strconv.ParseFloat(value, 64)
+				if err != nil || c < 0 || c > 1 {
+					return fmt.Errorf("invalid --lang-min-confidence %q: want a number from 0 to 1", value)
+				}
+				langMinConfidence = c
+			}
+			continue
+		case "--lang-summary":
+			lang = true
+			langSummary = true
+			continue
+		case "--script":
+			lang = true
+			script = true
+			continue
+		case "--lang-hint":
+			var list string
+			if parseStringValue(args, &i, &list) {
+				langHints = append(langHints, parseLanguageHints(list, cfg.ErrorOutput)...)
+			}
+			continue
+		case "--locale":
+			var list string
+			if parseStringValue(args, &i, &list) {
+				if locales == nil {
+					locales = make(map[string]string)
+				}
+				if err := parseLocales(list, locales); err != nil {
+					return err
+				}
+			}
+			continue
+		case "--lang-candidates":
+			lang = true
+			parseIntValue(args, &i, &langCandidates)
+			continue
+		case "--max-col-width":
+			parseIntValue(args, &i, &maxColWidth)
+			continue
+		case "--ngram":
+			parseIntValue(args, &i, &ngram)
+			continue
+		case "--suffix", "--prefix":
+			n := 0
+			if !parseIntValue(args, &i, &n) || n <= 0 {
+				return fmt.Errorf("invalid %s: want a positive number of characters", arg)
+			}
+			if arg == "--suffix" {
+				suffixLen = n
+			} else {
+				prefixLen = n
+			}
+			freq = true
+			continue
+		case "--tab-width":
+			if !parseIntValue(args, &i, &tabWidth) || tabWidth <= 0 {
+				return fmt.Errorf("invalid --tab-width: want a positive number of columns")
+			}
 			continue
 		}
 		
 		// Handle non-flag arguments (paths for all operations)
 		if !strings.HasPrefix(arg, "-") {
-			paths = append(paths, arg)
+			paths = append(paths, expandGlob(arg)...)
+			continue
+		}
+		
+		// Split bundled short flags like wc's -lwc into their letters
+		if len(arg) > 2 && arg[1] != '-' {
+			for _, letter := range arg[1:] {
+				switch letter {
+				case 'l':
+					l = true
+				case 'w':
+					w = true
+				case 'c':
+					c = true
+				case 'b':
+					b = true
+				case 'L':
+					maxLineLength = true
+				case 'R':
+					recursive = true
+				case 'f':
+					follow = true
+				case 'q':
+					quiet = true
+				case 'r':
+					reverse = true
+				case '0':
+					filesFrom = "-"
+					filesFromLines = false
+				default:
+					return fmt.Errorf("unknown flag -%c in %s", letter, arg)
+				}
+			}
 			continue
 		}
 	}
 	
 	// Update the configuration
 	cfg.LOC = loc
+	cfg.LOCVerbose = locVerbose
+	cfg.ListFiles = listFiles
+	cfg.CommentRatio = commentRatio
+	cfg.NoGenerated = noGenerated
+	cfg.SkipBinary = skipBinary
+	cfg.RespectGitignore = respectGitignore
+	cfg.FollowSymlinks = followSymlinks
+	cfg.Since = since
+	cfg.Recursive = recursive
+	cfg.ExcludePatterns = exclude
+	cfg.ExtraExtensions = extensions
+	cfg.OnlyExtensions = onlyExt
+	cfg.ManifestPath = manifest
 	cfg.Line = l
 	cfg.Char = c
+	cfg.Byte = b
+	cfg.Sentence = sentences
+	cfg.UniqueWords = unique
+	cfg.MaxLineLength = maxLineLength
+	cfg.AvgLineLength = avgLineLength
+	cfg.Stats = stats
+	cfg.Readability = readability
+	cfg.Density = density
+	cfg.Summary = summary
+	cfg.TTR = ttr
+	cfg.Entropy = entropy
+	cfg.EntropyBytes = entropyBytes
+	cfg.CapsStats = capsStats
+	cfg.LengthHistogram = lengthHistogram
+	cfg.LineLengthHistogram = lineLengthHistogram
+	cfg.BucketSize = bucketSize
 	cfg.DetectLanguage = lang
 	cfg.ShowLanguageName = langName
+	cfg.LangConfidence = langConfidence
+	cfg.LangMinWords = langMinWords
+	cfg.LangMinLength = langMinLength
+	cfg.LangMinConfidence = langMinConfidence
+	cfg.LangCandidates = langCandidates
+	cfg.ShowScript = script
+	cfg.LangSummary = langSummary
+	cfg.Locales = locales
+	cfg.LangHints = langHints
 	cfg.FrequencyAnalysis = freq
-	cfg.SortByCount = sortByCount
+	cfg.CharFrequency = charFreq
+	cfg.ShowPercent = showPercent
+	cfg.Cloud = cloud
+	cfg.WithPosition = withPosition
+	cfg.WordMode = wordMode
+	cfg.CJKMode = cjk
+	cfg.Distinct = distinct
+	cfg.TabWidth = tabWidth
+	cfg.Tokens = tokens
+	cfg.DupLines = dupLines
+	cfg.DupBlank = dupBlank
+	cfg.LineFrequency = lineFreq
+	cfg.TrimLines = trimLines
+	cfg.Anagrams = anagrams
+	cfg.CharWhitespace = charWhitespace
+	cfg.SortMode = sortMode
+	cfg.Reverse = reverse
+	cfg.Combined = combined
+	cfg.REPL = repl
+	cfg.FilesFrom = filesFrom
+	cfg.FilesFromLines = filesFromLines
+	cfg.Diff = diff
+	cfg.FilterStopwords = noStopwords
+	cfg.CaseSensitive = caseSensitive
+	cfg.TrimChars = trimChars
+	cfg.NoTrim = noTrim
+	cfg.Concat = concat
+	cfg.Sum = sum
+	cfg.DictPath = dictPath
+	cfg.CountWord = countWord
+	cfg.GrepPattern = grepPattern
+	cfg.GrepInvert = grepInvert
+	cfg.StripFrontMatter = stripFrontMatter
+	cfg.InputEncoding = inputEncoding
+	cfg.BOMReport = bomReport
+	cfg.LineEndings = lineEndings
+	cfg.WhitespaceReport = whitespaceReport
+	cfg.Normalize = normalize
+	cfg.CSVOutput = csvOutput
+	cfg.TSVOutput = tsvOutput
+	cfg.NDJSON = ndjson
+	cfg.JSONOutPath = jsonOut
+	cfg.Follow = follow
+	cfg.FollowInterval = followInterval
+	cfg.OutputPath = output
+	cfg.Quiet = quiet
+	cfg.NoHeader = noHeader
+	cfg.Human = human
+	cfg.Timing = timing
+	cfg.KeepGoing = keepGoing
+	cfg.HashAlgo = hashAlgo
+	if follow {
+		if err := checkFollowFlags(args); err != nil {
+			return err
+		}
+	}
+	if csvOutput && tsvOutput {
+		return fmt.Errorf("--csv and --tsv can't be used together")
+	}
+	otherReports := avgLineLength || stats || readability || density || summary || ttr || entropy || entropyBytes || capsStats || lengthHistogram || lineLengthHistogram ||
+		loc || manifest != "" || diff || repl || bomReport || dictPath != "" || countWord != "" || grepPattern != "" || tokens || dupLines || lineFreq || lineEndings || whitespaceReport || anagrams
+	if (csvOutput || tsvOutput) && (otherReports || lang) {
+		return fmt.Errorf("--csv and --tsv only work with counts, --freq and --char-freq")
+	}
+	if ndjson && (otherReports || langSummary) {
+		return fmt.Errorf("--ndjson only works with counts, --freq, --char-freq and --lang")
+	}
+	if jsonOut != "" && otherReports {
+		return fmt.Errorf("--json-out only works with counts, --freq, --char-freq and --lang")
+	}
+	if ndjson && (csvOutput || tsvOutput) {
+		return fmt.Errorf("--ndjson can't be used with --csv or --tsv")
+	}
+	if ndjson && jsonOut != "" {
+		return fmt.Errorf("--ndjson and --json-out can't be used together")
+	}
+	if cloud && (charFreq || csvOutput || tsvOutput || ndjson) {
+		return fmt.Errorf("--cloud can't be used with --char-freq, --csv, --tsv or --ndjson")
+	}
+	if suffixLen > 0 && prefixLen > 0 {
+		return fmt.Errorf("--suffix and --prefix can't be used together")
+	}
+	if (suffixLen > 0 || prefixLen > 0) && (charFreq || ngram > 1 || withPosition) {
+		return fmt.Errorf("--suffix and --prefix can't be used with --char-freq, --ngram or --with-position")
+	}
+	if withPosition && (charFreq || ngram > 1 || combined || cloud || csvOutput || tsvOutput || ndjson) {
+		return fmt.Errorf("--with-position only works with single-word frequency tables for each input")
+	}
+	if sum && concat {
+		return fmt.Errorf("--sum and --concat can't be used together")
+	}
+	if human && (csvOutput || tsvOutput || ndjson || manifest != "") {
+		return fmt.Errorf("--human can't be used with --csv, --tsv, --ndjson or --manifest, which are meant for machines")
+	}
+	if head > 0 && tail > 0 {
+		return fmt.Errorf("--head and --tail can't be used together")
+	}
+	if distinct && (!w || l || c || b || sentences || unique || maxLineLength) {
+		return fmt.Errorf("--distinct only works with -w on its own")
+	}
+	if bucketSize > 0 && !lineLengthHistogram {
+		return fmt.Errorf("--bucket-size only works with --line-length-histogram")
+	}
+	if trimLines && !lineFreq {
+		return fmt.Errorf("--trim-lines only works with --line-freq")
+	}
+	if grepInvert && grepPattern == "" {
+		return fmt.Errorf("--grep-invert only works with --grep")
+	}
+	if grepPattern != "" {
+		re, err := regexp.Compile(grepPattern)
+		if err != nil {
+			return fmt.Errorf("invalid --grep %q: %w", grepPattern, err)
+		}
+		cfg.GrepRegexp = re
+	}
+	if hashAlgo != "" && (freq || charFreq || lang || csvOutput || tsvOutput || ndjson || otherReports || concat || sum) {
+		return fmt.Errorf("--hash only works with line, word, character and byte counts of each input")
+	}
+	cfg.Head = head
+	cfg.SampleBytes = sampleBytes
+	cfg.SampleLines = sampleLines
+	cfg.Tail = tail
 	if limit > 0 {
 		cfg.FrequencyLimit = limit
+	} else if dupLines {
+		// Every repeated line is listed unless --limit asks for fewer
+		cfg.FrequencyLimit = 0
+	}
+	if minCount > 0 {
+		cfg.MinCount = minCount
+	}
+	if maxColWidth > 0 {
+		cfg.MaxColWidth = maxColWidth
+	}
+	cfg.SuffixLen = suffixLen
+	cfg.PrefixLen = prefixLen
+	if ngram > 0 {
+		cfg.NgramSize = ngram
+	}
+	if topChars > 0 {
+		cfg.TopChars = topChars
+		cfg.FrequencyLimit = topChars
 	}
 	
 	// Set default behavior to match wc: if no counting flags are specified, show lines, words, and chars
-	if !w && !l && !c && !loc && !lang && !freq {
+	if !w && !l && !c && !b && !sentences && !unique && !maxLineLength && !avgLineLength && !stats && !readability && !density && !summary && !ttr && !entropy && !entropyBytes && !capsStats && !lengthHistogram && !lineLengthHistogram && !loc && !lang && !freq && !charFreq && !diff && !tokens && !dupLines && !lineFreq && !anagrams && !bomReport && !lineEndings && !whitespaceReport && dictPath == "" && countWord == "" && grepPattern == "" && manifest == "" {
 		cfg.Line = true
 		cfg.Word = true 
 		cfg.Char = true
@@ -573,127 +1534,1445 @@ func ParseFlags(cfg *Config) {
 		cfg.Paths = paths
 	} else if loc || lang {
 		// Default to current directory for --loc (consistent with existing behavior),
-		// but don't default for language detection (will use stdin)
-		if loc {
+		// but don't default for language detection (will use stdin) or when the
+		// paths come from a file list
+		if loc && filesFrom == "" {
 			cfg.Paths = []string{"."}
 		}
 	}
+	
+	return nil
+}
+
+// expandGlob expands a path argument the shell left unexpanded (as on Windows).
+// Arguments without glob characters, malformed patterns and patterns that match
+// nothing are returned as-is so a mistyped path still reports "file not found".
+func expandGlob(arg string) []string {
+	if !strings.ContainsAny(arg, "*?[") {
+		return []string{arg}
+	}
+	matches, err := filepath.Glob(arg)
+	if err != nil || len(matches) == 0 {
+		return []string{arg}
+	}
+	return matches
+}
+
+// parseLocales adds the language=tag pairs from a comma-separated list such as
+// "en=en-GB,pt=pt-PT" to locales
+func parseLocales(list string, locales map[string]string) error {
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 || i == len(pair)-1 {
+			return fmt.Errorf("invalid locale %q, expected language=tag like en=en-GB", pair)
+		}
+		locales[strings.ToLower(pair[:i])] = pair[i+1:]
+	}
+	return nil
+}
+
+// parseLanguageHints splits a comma-separated list of language codes such as
+// "en,es,fr", warning on w about codes the detector doesn't know and leaving
+// them out
+func parseLanguageHints(list string, w io.Writer) []string {
+	var hints []string
+	for _, code := range strings.Split(list, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if !analyze.IsLanguageCode(code) {
+			fmt.Fprintf(w, "Warning: ignoring unknown language %q in --lang-hint\n", code)
+			continue
+		}
+		hints = append(hints, code)
+	}
+	return hints
+}
+
+// parseExtensions splits a comma-separated extension list, lowercasing each
+// entry and adding the leading dot if it was left off
+func parseExtensions(list string) []string {
+	var extensions []string
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions
+}
+
+// parseIntValue parses the argument following args[*i] as an integer into value.
+// On success it advances *i past the consumed argument and returns true.
+func parseIntValue(args []string, i *int, value *int) bool {
+	// Check if there's a next argument for the value
+	if *i+1 < len(args) {
+		// Try to parse the next argument as a number
+		if n, err := fmt.Sscanf(args[*i+1], "%d", value); n == 1 && err == nil {
+			// Skip the next arg since we've consumed it
+			*i++
+			return true
+		}
+	}
+	return false
+}
+
+// parseStringValue stores the argument following args[*i] into value.
+// On success it advances *i past the consumed argument and returns true.
+func parseStringValue(args []string, i *int, value *string) bool {
+	if *i+1 < len(args) {
+		*value = args[*i+1]
+		*i++
+		return true
+	}
+	return false
+}
+
+// Run executes the program with the given configuration
+func Run(cfg *Config) error {
+	// Write results to the named file instead of cfg.Output, creating it
+	// before any analysis so a bad path fails fast
+	if cfg.OutputPath != "" {
+		file, err := os.Create(cfg.OutputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file %s: %w", cfg.OutputPath, err)
+		}
+		
+		outputCfg := *cfg
+		outputCfg.OutputPath = ""
+		outputCfg.Output = file
+		if err := Run(&outputCfg); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write output file %s: %w", cfg.OutputPath, err)
+		}
+		return nil
+	}
+	
+	// Take the paths from a list instead of the command line
+	if cfg.FilesFrom != "" {
+		if len(cfg.Paths) > 0 {
+			return fmt.Errorf("%s can't be combined with file arguments", filesFromFlag(cfg))
+		}
+		paths, err := readFileList(cfg)
+		if err != nil {
+			return err
+		}
+		
+		// An empty list means there's nothing to analyze, not that stdin should be read
+		if len(paths) == 0 {
+			return nil
+		}
+		
+		listCfg := *cfg
+		listCfg.FilesFrom = ""
+		listCfg.FilesFromLines = false
+		listCfg.Paths = paths
+		return Run(&listCfg)
+	}
+	
+	// Following a file keeps counting until interrupted
+	if cfg.Follow {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		return followFile(cfg, interrupt)
+	}
+	
+	// LOC flag takes precedence
+	if cfg.LOC {
+		if err := countLinesOfCode(cfg); err != nil {
+			return err
+		}
+		return nil
+	}
+	
+	// Manifest mode walks the paths and writes its own output file
+	if cfg.ManifestPath != "" {
+		return writeManifest(cfg)
+	}
+	
+	// Everything else reads files, so expand directories into the files under them
+	paths, err := expandDirectories(cfg)
+	if err != nil {
+		return err
+	}
+	
+	// Paths that all turned out to be empty directories or skipped binary
+	// files leave nothing to count, rather than falling back to stdin
+	if len(cfg.Paths) > 0 && len(paths) == 0 {
+		return nil
+	}
+	if cfg.Recursive || cfg.SkipBinary {
+		expandedCfg := *cfg
+		expandedCfg.Paths = paths
+		cfg = &expandedCfg
+	}
+	
+	// Flag sampled results on stderr, after them, so the output itself is unchanged
+	if note := sampleNote(cfg); note != "" {
+		defer fmt.Fprintln(cfg.ErrorOutput, note)
+	}
+	
+	// Diff mode compares the word frequencies of two files
+	if cfg.Diff {
+		return runDiff(cfg)
+	}
+	
+	// REPL mode analyzes stdin a line at a time
+	if cfg.REPL {
+		return runREPL(cfg)
+	}
+	
+	// BOM mode reports on the raw input, before preprocessing drops the mark
+	if cfg.BOMReport {
+		return runBOMReport(cfg)
+	}
+	
+	// Apply any input preprocessing to stdin, hashing its own bytes first
+	// for --hash
+	stdin, stdinLabel := hashInput(cfg.Input, cfg)
+	input := prepareReader(stdin, cfg)
+	
+	// When concatenating, analyze all files as a single stream in place of stdin
+	if cfg.Concat && len(cfg.Paths) > 0 {
+		files, err := openConcatenated(cfg.Paths)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			for _, f := range files {
+				f.Close()
+			}
+		}()
+		
+		concatCfg := *cfg
+		concatCfg.Paths = nil
+		cfg = &concatCfg
+		input = concatReaders(files, cfg)
+	}
+	
+	// With --sum, stdin and every file are counted as one stream for a single total
+	if cfg.Sum {
+		files, err := openConcatenated(cfg.Paths)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			for _, f := range files {
+				f.Close()
+			}
+		}()
+		
+		// Leave stdin out when nothing was piped in, rather than wait on the terminal
+		var readers []io.Reader
+		if !isTerminal(cfg.Input) {
+			readers = append(readers, input)
+		}
+		for _, f := range files {
+			readers = append(readers, prepareReader(f, cfg))
+		}
+		
+		sumCfg := *cfg
+		sumCfg.Paths = nil
+		cfg = &sumCfg
+		input = io.MultiReader(readers...)
+	}
+	
+	// Dictionary mode lists the words that aren't in the word list
+	if cfg.DictPath != "" {
+		return runDictionaryCheck(input, cfg)
+	}
+	
+	// Count a single word's occurrences rather than every word
+	if cfg.CountWord != "" {
+		return runCountWord(input, cfg)
+	}
+	
+	// Count the lines matching a pattern, like grep -c
+	if cfg.GrepPattern != "" {
+		return runGrep(input, cfg)
+	}
+	
+	// Token mode prints the normalized words instead of counting them
+	if cfg.Tokens {
+		return printTokens(input, cfg)
+	}
+	
+	// Duplicate line mode lists the repeated lines instead of counting words
+	if cfg.DupLines {
+		return runDuplicateLines(input, cfg)
+	}
+	
+	// Line frequency mode counts whole lines instead of words
+	if cfg.LineFrequency {
+		return runLineFrequency(input, cfg)
+	}
+	
+	// Line ending mode reports how lines end instead of counting them
+	if cfg.LineEndings {
+		return runLineEndings(input, cfg)
+	}
+	
+	// Whitespace report mode checks for untidy whitespace instead of counting
+	if cfg.WhitespaceReport {
+		return runWhitespaceReport(input, cfg)
+	}
+	
+	// Anagram mode groups the words instead of counting them
+	if cfg.Anagrams {
+		return runAnagrams(input, cfg)
+	}
+	
+	// NDJSON writes each input's results as soon as they're ready
+	if cfg.NDJSON {
+		return writeNDJSON(input, cfg)
+	}
+	
+	// --json-out writes the same results to a file before the usual output,
+	// keeping stdin in memory so it can be read a second time
+	if cfg.JSONOutPath != "" {
+		jsonInput := input
+		if len(cfg.Paths) == 0 {
+			data, err := io.ReadAll(input)
+			if err != nil {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+			input = bytes.NewReader(data)
+			jsonInput = bytes.NewReader(data)
+		}
+		
+		// Unreadable files are reported once, by the usual output
+		jsonCfg := *cfg
+		jsonCfg.ErrorOutput = io.Discard
+		if err := writeJSONOut(jsonInput, &jsonCfg); err != nil {
+			return err
+		}
+	}
+	
+	// Time each input for --timing, reporting the total when Run is done
+	timer := &fileTimer{cfg: cfg}
+	defer timer.printTotal()
+	
+	// If we're detecting language, we need to handle the special case
+	if cfg.DetectLanguage {
+		// With --lang-summary, tally how many inputs are in each language,
+		// leaving out the per-file results with --quiet
+		var detected []analyze.LanguageCandidate
+		langCfg := cfg
+		if cfg.LangSummary && cfg.Quiet {
+			quietCfg := *cfg
+			quietCfg.Output = io.Discard
+			langCfg = &quietCfg
+		}
+		
+		// Check if paths are provided
+		errs := &fileErrors{cfg: cfg}
+		if len(cfg.Paths) > 0 {
+			// Process each file
+			for _, path := range cfg.Paths {
+				var language analyze.LanguageCandidate
+				err := timer.time(path, func() (err error) {
+					language, err = processFileForLanguage(path, langCfg)
+					return err
+				})
+				if err != nil {
+					if err := errs.check(err); err != nil {
+						return err
+					}
+					continue
+				}
+				detected = append(detected, language)
+			}
+		} else {
+			// No paths, process stdin
+			var language analyze.LanguageCandidate
+			err := timer.time("", func() (err error) {
+				language, err = processReaderForLanguage(input, langCfg)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			detected = append(detected, language)
+		}
+		
+		if cfg.LangSummary {
+			printLanguageSummary(cfg, detected)
+		}
+		return errs.err()
+	}
+	
+	// If we're doing word or character frequency analysis, handle that
+	if cfg.FrequencyAnalysis || cfg.CharFrequency {
+		// Delimited output covers every file in a single table
+		if cfg.CSVOutput || cfg.TSVOutput {
+			return writeFrequencyRecords(input, cfg)
+		}
+		
+		// Add every file's counts into one table rather than one table per file
+		if cfg.Combined && len(cfg.Paths) > 1 {
+			// With --keep-going the error only says some files failed, so the
+			// table still covers the rest
+			counts, err := combinedFrequencies(input, cfg)
+			if err != nil && !cfg.KeepGoing {
+				return err
+			}
+			printFrequencies(cfg, counts, fmt.Sprintf("%d files combined", len(cfg.Paths)))
+			return err
+		}
+		
+		// Check if paths are provided
+		if len(cfg.Paths) > 0 {
+			// Process each file
+			errs := &fileErrors{cfg: cfg}
+			for _, path := range cfg.Paths {
+				err := timer.time(path, func() error {
+					return processFileForFrequency(path, cfg)
+				})
+				if err := errs.check(err); err != nil {
+					return err
+				}
+			}
+			return errs.err()
+		}
+		
+		// No paths, process stdin
+		return timer.time("", func() error {
+			return processReaderForFrequency(input, cfg)
+		})
+	}
+	
+	// The average line length has its own layout, with a weighted total
+	if cfg.AvgLineLength {
+		return printAverageLineLengths(input, cfg, timer)
+	}
+	
+	// Delimited output covers every file in a single table
+	if cfg.CSVOutput || cfg.TSVOutput {
+		return writeCountRecords(input, cfg)
+	}
+	
+	// Handle standard counting options
+	// Check if paths are provided for standard counting
+	if len(cfg.Paths) > 0 {
+		// Process each file
+		totalLines, totalWords, totalChars := 0, 0, 0
+		showTotal := len(cfg.Paths) > 1 && cfg.Line && cfg.Word && cfg.Char
+		
+		// Like wc -L, the total for the longest line is the longest of any file
+		longestLine := 0
+		showLongest := len(cfg.Paths) > 1 && maxLineLengthOnly(cfg)
+		
+		// --head and --tail hide the other files' rows, but they're still counted for the total
+		hiddenCfg := *cfg
+		hiddenCfg.Output = io.Discard
+		
+		errs := &fileErrors{cfg: cfg}
+		for i, path := range cfg.Paths {
+			rowCfg := cfg
+			if !showsRow(cfg, i) {
+				rowCfg = &hiddenCfg
+			}
+			
+			var lines, words, chars int
+			err := timer.time(path, func() (err error) {
+				lines, words, chars, err = processFileForCounting(path, rowCfg)
+				return err
+			})
+			if err != nil {
+				if err := errs.check(err); err != nil {
+					return err
+				}
+				continue
+			}
+			
+			// If we're doing a wc-like output with multiple files, we need to track totals
+			if showTotal {
+				totalLines += lines
+				totalWords += words
+				totalChars += chars
+			}
+			if showLongest && lines > longestLine {
+				longestLine = lines
+			}
+		}
+		
+		// Display totals for multiple files
+		if showTotal {
+			printCounts(cfg, totalLines, totalWords, totalChars, "total")
+		}
+		if showLongest {
+			printCount(cfg, longestLine, "total")
+		}
+		
+		return errs.err()
+	}
+	
+	// No paths, process stdin
+	return timer.time("", func() error {
+		return processReaderForCounting(input, stdinLabel, cfg)
+	})
+}
+
+// showsRow reports whether the count row of the i'th path is printed, which
+// is all of them unless --head or --tail picks the first or last few
+func showsRow(cfg *Config, i int) bool {
+	switch {
+	case cfg.Head > 0:
+		return i < cfg.Head
+	case cfg.Tail > 0:
+		return i >= len(cfg.Paths)-cfg.Tail
+	}
+	return true
+}
+
+// processReaderForCounting handles standard counting operations for any io.Reader,
+// labeling the counts as label says, which adds the digest of the input for --hash
+func processReaderForCounting(r io.Reader, label func(path string) string, cfg *Config) error {
+	// A single count streams straight from the reader in constant memory
+	if !needsMultiplePasses(cfg) {
+		count, err := countSingle(r, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		
+		printCount(cfg, count, label(""))
+		return nil
+	}
+	
+	// Otherwise read all input into a buffer to allow multiple passes
+	inputData, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	
+	// Text reports like word statistics replace the counts entirely
+	if hasTextReports(cfg) {
+		printTextReports(cfg.Output, inputData, cfg)
+		return nil
+	}
+	
+	// Default behavior (like wc) shows all three counts
+	lineCount := analyze.CountLines(bytes.NewReader(inputData))
+	wordCount := countWords(bytes.NewReader(inputData), cfg)
+	charCount := countChars(bytes.NewReader(inputData), cfg)
+	
+	// Format output like wc: lines words chars
+	printCounts(cfg, lineCount, wordCount, charCount, label(""))
+	return nil
+}
+
+// printAverageLineLengths prints the mean characters per line of each input,
+// or 0 for an input without lines. For several files the total is the average
+// over all of their lines, not the mean of the per-file averages.
+func printAverageLineLengths(stdin io.Reader, cfg *Config, timer *fileTimer) error {
+	totalChars, totalLines := 0, 0
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		return timer.time(path, func() error {
+			cr := &checkedReader{r: r}
+			chars, lines := analyze.LineLengths(cr)
+			if cr.err != nil {
+				return fmt.Errorf("failed to read input: %w", cr.err)
+			}
+			
+			printAverage(cfg, averageLineLength(chars, lines), path)
+			totalChars += chars
+			totalLines += lines
+			return nil
+		})
+	})
+	// With --keep-going the error only says some files failed, so the total
+	// still covers the rest
+	if err != nil && !cfg.KeepGoing {
+		return err
+	}
+	
+	if len(cfg.Paths) > 1 {
+		printAverage(cfg, averageLineLength(totalChars, totalLines), "total")
+	}
+	return err
+}
+
+// averageLineLength divides chars by lines, returning 0 when there are no lines
+func averageLineLength(chars, lines int) float64 {
+	if lines == 0 {
+		return 0
+	}
+	return float64(chars) / float64(lines)
+}
+
+// printAverage prints an average for path (empty for stdin) in the same
+// layout as printCount, to two decimal places
+func printAverage(cfg *Config, average float64, path string) {
+	switch {
+	case cfg.Quiet && path != "":
+		fmt.Fprintf(cfg.Output, "%.2f %s\n", average, path)
+	case cfg.Quiet:
+		fmt.Fprintf(cfg.Output, "%.2f\n", average)
+	case path == "":
+		fmt.Fprintf(cfg.Output, "%8.2f\n", average)
+	default:
+		fmt.Fprintf(cfg.Output, "%8.2f %s\n", average, path)
+	}
+}
+
+// needsMultiplePasses reports whether the counting mode in cfg reads its input
+// more than once. Those modes buffer the whole input in memory, so on very large
+// files they cost as much memory as the file is big; every other mode streams.
+func needsMultiplePasses(cfg *Config) bool {
+	return hasTextReports(cfg) || (cfg.Line && cfg.Word && cfg.Char)
+}
+
+// countSingle streams r once to produce the single count selected in cfg,
+// returning any read error the counter would otherwise stop at silently
+func countSingle(r io.Reader, cfg *Config) (int, error) {
+	cr := &checkedReader{r: r}
+	
+	var count int
+	switch {
+	case cfg.Line:
+		count = analyze.CountLines(cr)
+	case cfg.Char:
+		count = countChars(cr, cfg)
+	case cfg.Byte:
+		count = analyze.CountBytes(cr)
+	case cfg.Sentence:
+		count = analyze.CountSentences(cr)
+	case cfg.UniqueWords:
+		count = analyze.CountUniqueWords(cr, wordOptions(cfg))
+	case cfg.MaxLineLength:
+		count = maxLineLength(cr, cfg)
+	case cfg.Word && cfg.Distinct:
+		count = countDistinctWords(cr, cfg)
+	case cfg.Word:
+		count = countWords(cr, cfg)
+	}
+	return count, cr.err
+}
+
+// countName names the single count selected in cfg, as countSingle picks it,
+// for the header of delimited output and the key of JSON output
+func countName(cfg *Config) string {
+	switch {
+	case cfg.Line:
+		return "lines"
+	case cfg.Char:
+		return "chars"
+	case cfg.Byte:
+		return "bytes"
+	case cfg.Sentence:
+		return "sentences"
+	case cfg.UniqueWords:
+		return "unique_words"
+	case cfg.MaxLineLength:
+		return "max_line_length"
+	case cfg.Word && cfg.Distinct:
+		return "distinct_words"
+	}
+	return "words"
+}
+
+// maxLineLengthOnly reports whether the single count selected in cfg is the
+// longest line, whose total across files is the maximum rather than the sum
+func maxLineLengthOnly(cfg *Config) bool {
+	return cfg.MaxLineLength && !cfg.Line && !cfg.Char && !cfg.Byte && !cfg.Sentence && !cfg.UniqueWords && !needsMultiplePasses(cfg)
+}
+
+// checkedReader remembers the first read error other than io.EOF, since the
+// counting functions treat any error as the end of the input
+type checkedReader struct {
+	r   io.Reader
+	err error
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// filesFromFlag names the flag cfg.FilesFrom came from, for error messages
+func filesFromFlag(cfg *Config) string {
+	if cfg.FilesFromLines {
+		return "--files-from"
+	}
+	return "--files0-from"
+}
+
+// readFileList reads the paths in cfg.FilesFrom, or in cfg.Input when it's
+// "-". The paths are NUL-separated, skipping empty names, or with
+// cfg.FilesFromLines one per line, skipping blank lines and # comments.
+func readFileList(cfg *Config) ([]string, error) {
+	var list []byte
+	var err error
+	if cfg.FilesFrom == "-" {
+		list, err = io.ReadAll(cfg.Input)
+	} else {
+		list, err = os.ReadFile(cfg.FilesFrom)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file list %s: %w", cfg.FilesFrom, err)
+	}
+	
+	var paths []string
+	if cfg.FilesFromLines {
+		for _, line := range strings.Split(string(list), "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			paths = append(paths, line)
+		}
+		return paths, nil
+	}
+	
+	for _, path := range strings.Split(string(list), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// runREPL reads stdin one line at a time, running the analysis selected in cfg
+// on each line and printing its result before reading the next, until EOF
+func runREPL(cfg *Config) error {
+	if len(cfg.Paths) > 0 {
+		return fmt.Errorf("--repl reads from stdin and can't be given files")
+	}
+	
+	reader := bufio.NewReader(prepareReader(cfg.Input, cfg))
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lineCfg := *cfg
+			lineCfg.REPL = false
+			lineCfg.StripFrontMatter = false
+			lineCfg.Input = strings.NewReader(line)
+			if err := Run(&lineCfg); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+	}
+}
+
+// gzipMagic is the two-byte signature at the start of every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openPath opens a file for analysis. Files ending in .gz or starting with the
+// gzip signature are decompressed transparently, with any decompression error
+// naming the file.
+func openPath(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	
+	r, err := decompress(file, path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileReader{Reader: r, file: file}, nil
+}
+
+// decompress returns r decompressed if path ends in .gz or r starts with the
+// gzip signature, or r as it is otherwise
+func decompress(r io.Reader, path string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(gzipMagic))
+	if !strings.HasSuffix(strings.ToLower(path), ".gz") && !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+	
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return &gzipErrorReader{zr: zr, path: path}, nil
+}
+
+// fileReader reads a possibly decompressed file and closes the underlying file
+type fileReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *fileReader) Close() error {
+	return f.file.Close()
+}
+
+// gzipErrorReader adds the file path to errors found partway through decompression,
+// such as a truncated stream or a bad checksum
+type gzipErrorReader struct {
+	zr   *gzip.Reader
+	path string
+}
+
+func (g *gzipErrorReader) Read(p []byte) (int, error) {
+	n, err := g.zr.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("failed to decompress %s: %w", g.path, err)
+	}
+	return n, err
+}
+
+// openConcatenated opens every path up front so that a missing file
+// is reported before any analysis output is produced
+func openConcatenated(paths []string) ([]io.ReadCloser, error) {
+	var files []io.ReadCloser
+	for _, path := range paths {
+		file, err := openPath(path)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// concatReaders joins the files into one stream with a newline between each
+func concatReaders(files []io.ReadCloser, cfg *Config) io.Reader {
+	var readers []io.Reader
+	for i, f := range files {
+		if i > 0 {
+			readers = append(readers, strings.NewReader("\n"))
+		}
+		readers = append(readers, prepareReader(f, cfg))
+	}
+	return io.MultiReader(readers...)
+}
+
+// prepareReader wraps an input reader with any preprocessing
+// requested in the configuration before it is analyzed
+func prepareReader(r io.Reader, cfg *Config) io.Reader {
+	// Everything after this sees UTF-8, whatever the input was written in
+	if enc := inputEncodings[cfg.InputEncoding]; enc != nil {
+		r = transform.NewReader(r, enc.NewDecoder())
+	}
+	
+	// A byte order mark is never content, so it's always dropped. Without
+	// --encoding, a UTF-16 one also switches to decoding UTF-16.
+	r = &bomReader{r: r, detectUTF16: cfg.InputEncoding == ""}
+	if cfg.Normalize {
+		r = normalizeASCII(r)
+	}
+	if cfg.StripFrontMatter {
+		r = &frontMatterReader{r: r}
+	}
+	
+	// A sample is the start of what would otherwise be analyzed
+	return sampleReader(r, cfg)
+}
+
+// utf8BOM is the byte order mark some Windows editors write at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// utf16BOMs are the little- and big-endian UTF-16 byte order marks
+var utf16BOMs = [][]byte{{0xFF, 0xFE}, {0xFE, 0xFF}}
+
+// bomReader drops a leading UTF-8 byte order mark so it isn't counted as a
+// character or attached to the first word. With detectUTF16 set, a UTF-16
+// byte order mark switches to decoding the rest of the input as UTF-16. Like
+// frontMatterReader, the check happens on the first read.
+type bomReader struct {
+	r           io.Reader
+	detectUTF16 bool
+	started     bool
+}
+
+func (b *bomReader) Read(p []byte) (int, error) {
+	if !b.started {
+		b.started = true
+		br := bufio.NewReader(b.r)
+		b.r = br
+		
+		// A short input still peeks what it has, so check the prefix and not the error
+		prefix, _ := br.Peek(len(utf8BOM))
+		if bytes.Equal(prefix, utf8BOM) {
+			br.Discard(len(utf8BOM))
+		} else if b.detectUTF16 {
+			for _, bom := range utf16BOMs {
+				if bytes.HasPrefix(prefix, bom) {
+					// The decoder reads the byte order from the mark and drops it
+					b.r = transform.NewReader(br, utf16Encoding.NewDecoder())
+					break
+				}
+			}
+		}
+	}
+	return b.r.Read(p)
+}
+
+// frontMatterReader skips a leading YAML front matter block delimited by "---" lines.
+// The block is only stripped when the input begins with the fence and the fence is
+// closed; otherwise the input is passed through untouched. Stripping happens lazily
+// on the first read so wrapping an unused reader (like stdin) never blocks.
+type frontMatterReader struct {
+	r       io.Reader
+	started bool
+}
+
+func (f *frontMatterReader) Read(p []byte) (int, error) {
+	if !f.started {
+		f.started = true
+		f.r = stripFrontMatter(f.r)
+	}
+	return f.r.Read(p)
+}
+
+// stripFrontMatter returns a reader positioned after the front matter block, if any
+func stripFrontMatter(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	
+	// Keep everything consumed so it can be replayed if there's no closing fence
+	var consumed bytes.Buffer
+	for lineNum := 0; ; lineNum++ {
+		line, err := br.ReadString('\n')
+		consumed.WriteString(line)
+		
+		isFence := strings.TrimRight(line, "\r\n") == "---"
+		if lineNum == 0 && !isFence {
+			// Not front matter; only the opening line needs replaying
+			break
+		}
+		if lineNum > 0 && isFence {
+			// Found the closing fence, continue after it
+			return br
+		}
+		if err != nil {
+			break
+		}
+	}
+	
+	return io.MultiReader(&consumed, br)
+}
+
+// processFileForLanguage handles language detection for a specific file,
+// returning the language detected
+func processFileForLanguage(path string, cfg *Config) (analyze.LanguageCandidate, error) {
+	// Open the file
+	file, err := openPath(path)
+	if err != nil {
+		return analyze.LanguageCandidate{}, err
+	}
+	defer file.Close()
+	
+	// If multiple files, print the filename
+	if len(cfg.Paths) > 1 {
+		fmt.Fprintf(cfg.Output, "%s:\n", path)
+	}
+	
+	// Process the file
+	return processReaderForLanguage(prepareReader(file, cfg), cfg)
+}
+
+// languageOptions builds the language detection options from the configuration
+func languageOptions(cfg *Config) analyze.LanguageOptions {
+	return analyze.LanguageOptions{
+		MinWords:      cfg.LangMinWords,
+		MinConfidence: cfg.LangMinConfidence,
+		Regions:       cfg.Locales,
+		Hints:         cfg.LangHints,
+	}
+}
+
+// processReaderForLanguage handles language detection for any io.Reader,
+// returning the language detected (the best candidate with --lang-candidates)
+func processReaderForLanguage(r io.Reader, cfg *Config) (analyze.LanguageCandidate, error) {
+	// Create a buffer to allow reading the input twice
+	var buf bytes.Buffer
+	tee := io.TeeReader(r, &buf)
+	
+	// First pass: detect language, ranking several candidates if requested
+	var langTag, langName string
+	var confidence float64
+	var candidates []analyze.LanguageCandidate
+	var err error
+	if cfg.LangCandidates > 0 {
+		candidates, err = analyze.DetectLanguageCandidates(tee, cfg.LangCandidates, languageOptions(cfg))
+	} else {
+		langTag, langName, confidence, err = analyze.DetectLanguage(tee, languageOptions(cfg))
+	}
+	if err != nil {
+		return analyze.LanguageCandidate{}, fmt.Errorf("failed to detect language: %w", err)
+	}
+	
+	// Warn when the text is too short for the guess to mean much
+	if cfg.LangMinLength > 0 {
+		if length := utf8.RuneCount(bytes.TrimSpace(buf.Bytes())); length < cfg.LangMinLength {
+			fmt.Fprintf(cfg.ErrorOutput, "Warning: only %d characters (--lang-min-length %d), language detection is unreliable\n", length, cfg.LangMinLength)
+		}
+	}
+	
+	// Detect the script from the same text if requested
+	var script string
+	if cfg.ShowScript {
+		script, err = analyze.DetectScript(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return analyze.LanguageCandidate{}, fmt.Errorf("failed to detect script: %w", err)
+		}
+	}
+	
+	// Second pass: handle standard counting options if requested
+	var count int
+	var needsCount bool
+	switch {
+	case cfg.Line:
+		count = analyze.CountLines(&buf)
+		needsCount = true
+	case cfg.Char:
+		count = countChars(&buf, cfg)
+		needsCount = true
+	case cfg.Byte:
+		count = analyze.CountBytes(&buf)
+		needsCount = true
+	case cfg.Sentence:
+		count = analyze.CountSentences(&buf)
+		needsCount = true
+	case cfg.UniqueWords:
+		count = analyze.CountUniqueWords(&buf, wordOptions(cfg))
+		needsCount = true
+	case cfg.MaxLineLength:
+		count = maxLineLength(&buf, cfg)
+		needsCount = true
+	case cfg.Word && cfg.Distinct:
+		count = countDistinctWords(&buf, cfg)
+		needsCount = true
+	case cfg.Word:
+		count = countWords(&buf, cfg)
+		needsCount = true
+	}
+	
+	// Print language info, leaving off the labels with --no-header
+	language := langTag
+	if cfg.ShowLanguageName {
+		language = langName
+	}
+	prefix := "Language: "
+	if cfg.NoHeader {
+		prefix = ""
+	}
+	if candidates != nil {
+		if !cfg.NoHeader {
+			fmt.Fprintf(cfg.Output, "Language candidates:\n")
+		}
+		for i, candidate := range candidates {
+			language = candidate.Tag
+			if cfg.ShowLanguageName {
+				language = candidate.Name
+			}
+			fmt.Fprintf(cfg.Output, "%d. %s (score: %.2f)\n", i+1, language, candidate.Score)
+		}
+	} else if cfg.LangConfidence {
+		fmt.Fprintf(cfg.Output, "%s%s (confidence: %.2f)\n", prefix, language, confidence)
+	} else {
+		fmt.Fprintf(cfg.Output, "%s%s\n", prefix, language)
+	}
+	
+	// Print script info
+	if cfg.ShowScript {
+		fmt.Fprintf(cfg.Output, "Script: %s\n", script)
+	}
+	
+	// Print count if needed
+	if needsCount {
+		fmt.Fprintf(cfg.Output, "Count: %s\n", formatCount(cfg, count))
+	}
+	
+	if candidates != nil {
+		return candidates[0], nil
+	}
+	return analyze.LanguageCandidate{Tag: langTag, Name: langName, Score: confidence}, nil
+}
+
+// printLanguageSummary prints how many inputs were detected in each language,
+// most common first with ties in tag order
+func printLanguageSummary(cfg *Config, detected []analyze.LanguageCandidate) {
+	tally := make(map[string]int)
+	names := make(map[string]string)
+	for _, language := range detected {
+		tally[language.Tag]++
+		names[language.Tag] = language.Name
+	}
+	
+	tags := make([]string, 0, len(tally))
+	for tag := range tally {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tally[tags[i]] == tally[tags[j]] {
+			return tags[i] < tags[j]
+		}
+		return tally[tags[i]] > tally[tags[j]]
+	})
+	
+	// Label each row with the tag, or the name with --lang-name
+	labels := make([]string, len(tags))
+	maxLabelLen := 0
+	for i, tag := range tags {
+		labels[i] = tag
+		if cfg.ShowLanguageName {
+			labels[i] = names[tag]
+		}
+		if n := len([]rune(labels[i])); n > maxLabelLen {
+			maxLabelLen = n
+		}
+	}
+	
+	fmt.Fprintf(cfg.Output, "Language summary:\n")
+	for i, tag := range tags {
+		fmt.Fprintf(cfg.Output, "%-*s  %6d\n", maxLabelLen, labels[i], tally[tag])
+	}
+}
+
+// FormatLikeWC formats counts exactly like the wc utility
+func FormatLikeWC(w io.Writer, lineCount, wordCount, charCount int, path string) {
+	formatFieldsLikeWC(w, strconv.Itoa(lineCount), strconv.Itoa(wordCount), strconv.Itoa(charCount), path)
+}
+
+// formatFieldsLikeWC writes already formatted counts with wc's column widths
+func formatFieldsLikeWC(w io.Writer, lineCount, wordCount, charCount string, path string) {
+	// Exact format string to match wc output
+	// The key is to use the spacing for consistent results
+	if path == "" {
+		// No extra space at the end for stdin
+		fmt.Fprintf(w, "%8s %7s %7s", lineCount, wordCount, charCount)
+	} else {
+		// With path
+		fmt.Fprintf(w, "%8s %7s %7s %s", lineCount, wordCount, charCount, path)
+	}
+	// Use Fprintln to add the newline exactly like wc does
+	fmt.Fprintln(w)
+}
+
+// humanizeCount shortens counts of 1000 or more with an SI suffix and one
+// decimal place, so 1234 becomes "1.2K" and 1500000 becomes "1.5M"
+func humanizeCount(n int) string {
+	if n < 1000 && n > -1000 {
+		return strconv.Itoa(n)
+	}
+	
+	value := float64(n)
+	suffix := ""
+	for _, unit := range []string{"K", "M", "G", "T", "P", "E"} {
+		value /= 1000
+		suffix = unit
+		// Move up a unit rather than round to "1000.0K"
+		if value < 999.95 && value > -999.95 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+// formatCount formats a count for output, shortened with cfg.Human
+func formatCount(cfg *Config, n int) string {
+	if cfg.Human {
+		return humanizeCount(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// printCounts prints the line, word and character counts for path (empty for
+// stdin) like wc, or as bare space-separated numbers with cfg.Quiet
+func printCounts(cfg *Config, lineCount, wordCount, charCount int, path string) {
+	if cfg.Quiet {
+		printQuiet(cfg, path, lineCount, wordCount, charCount)
+		return
+	}
+	formatFieldsLikeWC(cfg.Output, formatCount(cfg, lineCount), formatCount(cfg, wordCount), formatCount(cfg, charCount), path)
+}
+
+// printCount prints a single count for path (empty for stdin) with wc's
+// spacing, or as a bare number with cfg.Quiet
+func printCount(cfg *Config, count int, path string) {
+	switch {
+	case cfg.Quiet:
+		printQuiet(cfg, path, count)
+	case path == "":
+		// Match wc's spacing for output without a filename (no trailing space)
+		fmt.Fprintf(cfg.Output, "%8s\n", formatCount(cfg, count))
+	default:
+		fmt.Fprintf(cfg.Output, "%8s %s\n", formatCount(cfg, count), path)
+	}
+}
+
+// printQuiet prints counts without padding, separated by single spaces and
+// followed by the path when there is one
+func printQuiet(cfg *Config, path string, counts ...int) {
+	fields := make([]string, 0, len(counts)+1)
+	for _, count := range counts {
+		fields = append(fields, formatCount(cfg, count))
+	}
+	if path != "" {
+		fields = append(fields, path)
+	}
+	fmt.Fprintln(cfg.Output, strings.Join(fields, " "))
+}
+
+// hasTextReports reports whether cfg asks for any of the text reports
+// printed by printTextReports in place of the usual counts
+func hasTextReports(cfg *Config) bool {
+	return cfg.Summary || cfg.Stats || cfg.Readability || cfg.Density || cfg.TTR || cfg.Entropy || cfg.EntropyBytes || cfg.CapsStats || cfg.LengthHistogram || cfg.LineLengthHistogram
+}
+
+// printTextReports prints the word statistics, readability and other text reports requested in cfg
+func printTextReports(w io.Writer, data []byte, cfg *Config) {
+	if cfg.Summary {
+		summary := analyze.Summary(bytes.NewReader(data), wordOptions(cfg))
+		fmt.Fprintf(w, "Lines: %d\n", summary.Lines)
+		fmt.Fprintf(w, "Words: %d\n", summary.Words)
+		fmt.Fprintf(w, "Characters: %d\n", summary.Chars)
+		fmt.Fprintf(w, "Bytes: %d\n", summary.Bytes)
+		fmt.Fprintf(w, "Sentences: %d\n", summary.Sentences)
+		fmt.Fprintf(w, "Paragraphs: %d\n", summary.Paragraphs)
+	}
+	if cfg.Stats {
+		printWordStats(w, bytes.NewReader(data), frequencyOptions(cfg))
+	}
+	if cfg.Readability {
+		score := analyze.FleschReadingEase(bytes.NewReader(data))
+		fmt.Fprintf(w, "Readability: %.2f (%s)\n", score, analyze.ReadabilityLabel(score))
+	}
+	if cfg.Density {
+		density := analyze.Density(bytes.NewReader(data), wordOptions(cfg))
+		fmt.Fprintf(w, "Words: %d\n", density.Words)
+		fmt.Fprintf(w, "Sentences: %d\n", density.Sentences)
+		fmt.Fprintf(w, "Paragraphs: %d\n", density.Paragraphs)
+		fmt.Fprintf(w, "Words per sentence: %.2f\n", density.WordsPerSentence)
+		fmt.Fprintf(w, "Words per paragraph: %.2f\n", density.WordsPerParagraph)
+	}
+	if cfg.TTR {
+		// Reading from memory can't fail
+		ttr, _ := analyze.TypeTokenRatio(bytes.NewReader(data), frequencyOptions(cfg))
+		fmt.Fprintf(w, "Types: %d\n", ttr.Types)
+		fmt.Fprintf(w, "Tokens: %d\n", ttr.Tokens)
+		fmt.Fprintf(w, "Type-token ratio: %.4f\n", ttr.Ratio)
+	}
+	if cfg.Entropy {
+		fmt.Fprintf(w, "Entropy: %.4f bits per character\n", analyze.ShannonEntropy(bytes.NewReader(data)))
+	}
+	if cfg.EntropyBytes {
+		fmt.Fprintf(w, "Byte entropy: %.4f bits per byte\n", analyze.ByteEntropy(bytes.NewReader(data)))
+	}
+	if cfg.CapsStats {
+		printCapsStats(w, bytes.NewReader(data), frequencyOptions(cfg))
+	}
+	if cfg.LengthHistogram {
+		printHistogram(w, "Word length histogram:", analyze.WordLengthHistogram(bytes.NewReader(data), frequencyOptions(cfg)), terminalWidth())
+	}
+	if cfg.LineLengthHistogram {
+		printLineLengthHistogram(w, bytes.NewReader(data), cfg.BucketSize, terminalWidth())
+	}
+}
+
+// defaultTerminalWidth is the width histograms are scaled to when $COLUMNS isn't set
+const defaultTerminalWidth = 80
+
+// terminalWidth returns the width from $COLUMNS, or defaultTerminalWidth
+func terminalWidth() int {
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// printHistogram prints one row per bucket in ascending order with its count and
+// a bar of '#' characters, scaling the longest bar so each row fits in width columns.
+// Buckets with no entries are left out so a far outlier doesn't add empty rows.
+func printHistogram(w io.Writer, header string, histogram map[int]int, width int) {
+	printLabeledHistogram(w, header, histogram, width, strconv.Itoa)
+}
+
+// printLabeledHistogram is printHistogram with each bucket shown as label returns it
+func printLabeledHistogram(w io.Writer, header string, histogram map[int]int, width int, label func(bucket int) string) {
+	fmt.Fprintln(w, header)
+	
+	var buckets []int
+	maxCount := 0
+	for bucket, count := range histogram {
+		buckets = append(buckets, bucket)
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	sort.Ints(buckets)
+	if len(buckets) == 0 {
+		return
+	}
+	
+	// Size the label columns to the widest bucket label and largest count
+	bucketWidth := 0
+	for _, bucket := range buckets {
+		if n := len([]rune(label(bucket))); n > bucketWidth {
+			bucketWidth = n
+		}
+	}
+	countWidth := len(strconv.Itoa(maxCount))
+	barWidth := width - bucketWidth - countWidth - 4
+	if barWidth < 1 {
+		barWidth = 1
+	}
+	
+	for _, bucket := range buckets {
+		count := histogram[bucket]
+		
+		// Every non-empty bucket gets at least one '#'
+		bar := count * barWidth / maxCount
+		if bar == 0 {
+			bar = 1
+		}
+		fmt.Fprintf(w, "%*s  %*d  %s\n", bucketWidth, label(bucket), countWidth, count, strings.Repeat("#", bar))
+	}
+}
+
+// defaultBucketSize is how many columns wide each --line-length-histogram bucket is
+// when --bucket-size isn't given
+const defaultBucketSize = 10
+
+// lineLengthBuckets counts the lines of r by length in characters, not counting
+// line endings, keyed by the shortest length in each bucket of bucketSize lengths
+func lineLengthBuckets(r io.Reader, bucketSize int) map[int]int {
+	if bucketSize < 1 {
+		bucketSize = defaultBucketSize
+	}
+	
+	reader := bufio.NewReader(r)
+	buckets := make(map[int]int)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			length := utf8.RuneCountInString(line)
+			buckets[length/bucketSize*bucketSize]++
+		}
+		if err != nil {
+			break
+		}
+	}
+	return buckets
 }
 
-// Run executes the program with the given configuration
-func Run(cfg *Config) error {
-	// LOC flag takes precedence
-	if cfg.LOC {
-		if err := countLinesOfCode(cfg.Paths); err != nil {
-			return err
+// printLineLengthHistogram prints how many lines of r fall in each bucket of
+// line lengths, labeling each bucket with the range of lengths it holds
+func printLineLengthHistogram(w io.Writer, r io.Reader, bucketSize int, width int) {
+	if bucketSize < 1 {
+		bucketSize = defaultBucketSize
+	}
+	label := func(bucket int) string {
+		if bucketSize == 1 {
+			return strconv.Itoa(bucket)
 		}
-		return nil
+		return fmt.Sprintf("%d-%d", bucket, bucket+bucketSize-1)
 	}
-	
-	// If we're detecting language, we need to handle the special case
-	if cfg.DetectLanguage {
-		// Check if paths are provided
-		if len(cfg.Paths) > 0 {
-			// Process each file
-			for _, path := range cfg.Paths {
-				if err := processFileForLanguage(path, cfg); err != nil {
-					return err
-				}
-			}
-			return nil
+	printLabeledHistogram(w, "Line length histogram:", lineLengthBuckets(r, bucketSize), width, label)
+}
+
+// printCapsStats prints how many words are in each capitalization style and
+// what percentage of the words with cased letters that is
+func printCapsStats(w io.Writer, r io.Reader, opts analyze.FrequencyOptions) {
+	allCaps, capitalized, lower := analyze.CapsStats(r, opts)
+	total := allCaps + capitalized + lower
+	percent := func(n int) float64 {
+		if total == 0 {
+			return 0
 		}
-		
-		// No paths, process stdin
-		return processReaderForLanguage(cfg.Input, cfg)
+		return 100 * float64(n) / float64(total)
 	}
 	
-	// If we're doing frequency analysis, handle that
-	if cfg.FrequencyAnalysis {
-		// Check if paths are provided
-		if len(cfg.Paths) > 0 {
-			// Process each file
-			for _, path := range cfg.Paths {
-				if err := processFileForFrequency(path, cfg); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
-		
-		// No paths, process stdin
-		return processReaderForFrequency(cfg.Input, cfg)
+	fmt.Fprintf(w, "All caps:    %d (%.2f%%)\n", allCaps, percent(allCaps))
+	fmt.Fprintf(w, "Capitalized: %d (%.2f%%)\n", capitalized, percent(capitalized))
+	fmt.Fprintf(w, "Lowercase:   %d (%.2f%%)\n", lower, percent(lower))
+}
+
+// printWordStats prints the average word length and the longest and shortest words
+func printWordStats(w io.Writer, r io.Reader, opts analyze.FrequencyOptions) {
+	avg, longest, shortest := analyze.WordStats(r, opts)
+	fmt.Fprintf(w, "Average word length: %.2f\n", avg)
+	fmt.Fprintf(w, "Longest word: %s\n", longest)
+	fmt.Fprintf(w, "Shortest word: %s\n", shortest)
+}
+
+// processFileForCounting handles standard counting operations for a specific file
+// returns lineCount, wordCount, charCount, and error. A single count, such as
+// the longest line with -L, comes back as the first value.
+func processFileForCounting(path string, cfg *Config) (int, int, int, error) {
+	// Open the file, hashing its own bytes as they're counted if asked to
+	file, label, err := openHashed(path, cfg)
+	if err != nil {
+		return 0, 0, 0, err
 	}
+	defer file.Close()
+	r := prepareReader(file, cfg)
 	
-	// Handle standard counting options
-	// Check if paths are provided for standard counting
-	if len(cfg.Paths) > 0 {
-		// Process each file
-		totalLines, totalWords, totalChars := 0, 0, 0
-		showTotal := len(cfg.Paths) > 1 && cfg.Line && cfg.Word && cfg.Char
-		
-		for _, path := range cfg.Paths {
-			lines, words, chars, err := processFileForCounting(path, cfg)
-			if err != nil {
-				return err
-			}
-			
-			// If we're doing a wc-like output with multiple files, we need to track totals
-			if showTotal {
-				totalLines += lines
-				totalWords += words
-				totalChars += chars
-			}
+	// A single count streams straight from the file in constant memory
+	if !needsMultiplePasses(cfg) {
+		count, err := countSingle(r, cfg)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to read file %s: %w", path, err)
 		}
 		
-		// Display totals for multiple files
-		if showTotal {
-			FormatLikeWC(cfg.Output, totalLines, totalWords, totalChars, "total")
-		}
+		// Print with filename, using the same spacing as wc
+		printCount(cfg, count, label(path))
 		
-		return nil
+		return count, 0, 0, nil
 	}
 	
-	// No paths, process stdin for standard counting
-	// Read all input into a buffer to allow multiple passes
-	inputData, err := io.ReadAll(cfg.Input)
+	// Read the file contents to handle multiple passes
+	fileContents, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
+		return 0, 0, 0, fmt.Errorf("failed to read file %s: %w", path, err) 
 	}
 	
-	// If default behavior (like wc), show all three counts
-	if cfg.Line && cfg.Word && cfg.Char {
-		lineCount := countLines(bytes.NewReader(inputData))
-		wordCount := countWords(bytes.NewReader(inputData))
-		charCount := countChars(bytes.NewReader(inputData))
-		
-		// Format output like wc: lines words chars
-		FormatLikeWC(cfg.Output, lineCount, wordCount, charCount, "")
-		return nil
+	// Text reports like word statistics replace the counts entirely
+	if hasTextReports(cfg) {
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		printTextReports(cfg.Output, fileContents, cfg)
+		return 0, 0, 0, nil
 	}
 	
-	// Otherwise handle individual flags
-	var count int
-	switch {
-	case cfg.Line:
-		count = countLines(bytes.NewReader(inputData))
-	case cfg.Char:
-		count = countChars(bytes.NewReader(inputData))
-	case cfg.Word:
-		count = countWords(bytes.NewReader(inputData))
-	}
+	// Default behavior (like wc) shows all three counts
+	lineCount := analyze.CountLines(bytes.NewReader(fileContents))
+	wordCount := countWords(bytes.NewReader(fileContents), cfg)
+	charCount := countChars(bytes.NewReader(fileContents), cfg)
 	
-	// Match wc's spacing for output without a filename (no trailing space)
-	fmt.Fprintf(cfg.Output, "%8d", count)
-	fmt.Fprintln(cfg.Output)
-	return nil
+	// Use our wc-like formatter
+	printCounts(cfg, lineCount, wordCount, charCount, label(path))
+	return lineCount, wordCount, charCount, nil
 }
 
-// processFileForLanguage handles language detection for a specific file
-func processFileForLanguage(path string, cfg *Config) error {
+// processFileForFrequency handles word frequency analysis for a specific file
+func processFileForFrequency(path string, cfg *Config) error {
 	// Open the file
-	file, err := os.Open(path)
+	file, err := openPath(path)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", path, err)
+		return err
 	}
 	defer file.Close()
 	
@@ -703,166 +2982,420 @@ func processFileForLanguage(path string, cfg *Config) error {
 	}
 	
 	// Process the file
-	return processReaderForLanguage(file, cfg)
+	return processReaderForFrequency(prepareReader(file, cfg), cfg)
 }
 
-// processReaderForLanguage handles language detection for any io.Reader
-func processReaderForLanguage(r io.Reader, cfg *Config) error {
-	// Create a buffer to allow reading the input twice
-	var buf bytes.Buffer
-	tee := io.TeeReader(r, &buf)
-	
-	// First pass: detect language
-	langTag, langName, err := detectLanguage(tee)
+// frequencyOptions builds the word normalization options from the configuration
+func frequencyOptions(cfg *Config) analyze.FrequencyOptions {
+	return analyze.FrequencyOptions{
+		FilterStopwords:   cfg.FilterStopwords,
+		MinCount:          cfg.MinCount,
+		IncludeWhitespace: cfg.CharWhitespace,
+		CaseSensitive:     cfg.CaseSensitive,
+		TrimChars:         cfg.TrimChars,
+		NoTrim:            cfg.NoTrim,
+		Reverse:           cfg.Reverse,
+		WordMode:          analyze.WordMode(cfg.WordMode),
+		CJK:               cfg.CJKMode,
+	}
+}
+
+// wordOptions splits words as --word-mode and --cjk select, for the counts
+// and reports that otherwise count every word as it's written
+func wordOptions(cfg *Config) analyze.FrequencyOptions {
+	return analyze.FrequencyOptions{
+		WordMode: analyze.WordMode(cfg.WordMode),
+		CJK:      cfg.CJKMode,
+	}
+}
+
+// countChars counts the characters in r, expanding tabs with --tab-width
+func countChars(r io.Reader, cfg *Config) int {
+	return analyze.CountCharsTabs(r, cfg.TabWidth)
+}
+
+// maxLineLength measures the longest line in r, with tab stops every
+// --tab-width columns (8 by default, like wc -L)
+func maxLineLength(r io.Reader, cfg *Config) int {
+	return analyze.MaxLineLengthTabs(r, cfg.TabWidth)
+}
+
+// countWords counts the words in r, separated as --word-mode selects and
+// with each Han and Kana character a word of its own with --cjk
+func countWords(r io.Reader, cfg *Config) int {
+	if cfg.CJKMode {
+		return analyze.CountWordsSplit(r, analyze.WordMode(cfg.WordMode).CJKSplitFunc())
+	}
+	return analyze.CountWordsMode(r, analyze.WordMode(cfg.WordMode))
+}
+
+// countDistinctWords counts the different words in r for --distinct, telling
+// them apart as frequency analysis does, so case matters with --case-sensitive
+func countDistinctWords(r io.Reader, cfg *Config) int {
+	counts, _ := analyze.WordCounts(r, frequencyOptions(cfg))
+	return len(counts)
+}
+
+// processReaderForFrequency handles word frequency analysis for any io.Reader
+func processReaderForFrequency(r io.Reader, cfg *Config) error {
+	counts, err := countFrequencies(r, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to detect language: %w", err)
+		return err
 	}
 	
-	// Second pass: handle standard counting options if requested
-	var count int
-	var needsCount bool
-	switch {
-	case cfg.Line:
-		count = countLines(&buf)
-		needsCount = true
-	case cfg.Char:
-		count = countChars(&buf)
-		needsCount = true
-	case cfg.Word:
-		count = countWords(&buf)
-		needsCount = true
+	printFrequencies(cfg, counts, "")
+	return nil
+}
+
+// frequencyCounts holds the raw counts behind a frequency table, before it's sorted and limited
+type frequencyCounts struct {
+	words     map[string]int // Word or n-gram counts
+	chars     map[rune]int   // Character counts for --char-freq
+	distinct  map[string]int // Distinct words for --unique
+	firstSeen map[string]int // Position of each word's first appearance for --with-position
+}
+
+// countFrequencies counts the words, n-grams or characters in r as cfg asks
+func countFrequencies(r io.Reader, cfg *Config) (frequencyCounts, error) {
+	var counts frequencyCounts
+	
+	if cfg.CharFrequency {
+		chars, err := analyze.CharCounts(r, frequencyOptions(cfg))
+		if err != nil {
+			return counts, fmt.Errorf("failed to analyze character frequency: %w", err)
+		}
+		counts.chars = chars
+		return counts, nil
 	}
 	
-	// Print language info
-	if cfg.ShowLanguageName {
-		fmt.Fprintf(cfg.Output, "Language: %s\n", langName)
-	} else {
-		fmt.Fprintf(cfg.Output, "Language: %s\n", langTag)
+	// The distinct words for --unique need their own pass over the input, which
+	// runs alongside the frequency analysis so the input is never buffered
+	var distinct chan map[string]int
+	var pw *io.PipeWriter
+	if cfg.UniqueWords {
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		r = io.TeeReader(r, pw)
+		distinct = make(chan map[string]int, 1)
+		go func() {
+			words, _ := analyze.WordCounts(pr, wordOptions(cfg))
+			// Drain whatever the counter left so writes to the pipe never block
+			io.Copy(io.Discard, pr)
+			distinct <- words
+		}()
 	}
 	
-	// Print count if needed
-	if needsCount {
-		fmt.Fprintf(cfg.Output, "Count: %d\n", count)
+	var words map[string]int
+	var err error
+	switch {
+	case cfg.WithPosition:
+		words, counts.firstSeen, err = analyze.WordPositions(r, frequencyOptions(cfg))
+	case cfg.SuffixLen > 0:
+		words, err = analyze.AffixCounts(r, cfg.SuffixLen, true, frequencyOptions(cfg))
+	case cfg.PrefixLen > 0:
+		words, err = analyze.AffixCounts(r, cfg.PrefixLen, false, frequencyOptions(cfg))
+	default:
+		words, err = analyze.NgramCounts(r, cfg.NgramSize, frequencyOptions(cfg))
+	}
+	if pw != nil {
+		pw.Close()
+		counts.distinct = <-distinct
 	}
+	if err != nil {
+		return counts, fmt.Errorf("failed to analyze word frequency: %w", err)
+	}
+	counts.words = words
+	return counts, nil
+}
+
+// add merges the counts from other into fc, for --combined
+func (fc *frequencyCounts) add(other frequencyCounts) {
+	merge := func(into *map[string]int, from map[string]int) {
+		if from == nil {
+			return
+		}
+		if *into == nil {
+			*into = make(map[string]int)
+		}
+		for word, count := range from {
+			(*into)[word] += count
+		}
+	}
+	merge(&fc.words, other.words)
+	merge(&fc.distinct, other.distinct)
 	
-	return nil
+	if other.chars != nil {
+		if fc.chars == nil {
+			fc.chars = make(map[rune]int)
+		}
+		for c, count := range other.chars {
+			fc.chars[c] += count
+		}
+	}
 }
 
-// FormatLikeWC formats counts exactly like the wc utility
-func FormatLikeWC(w io.Writer, lineCount, wordCount, charCount int, path string) {
-	// Exact format string to match wc output
-	// The key is to use the spacing for consistent results
-	if path == "" {
-		// No extra space at the end for stdin
-		fmt.Fprintf(w, "%8d %7d %7d", lineCount, wordCount, charCount)
-	} else {
-		// With path
-		fmt.Fprintf(w, "%8d %7d %7d %s", lineCount, wordCount, charCount, path)
+// sortOrder describes the frequency sort selected in cfg for table headers
+func sortOrder(cfg *Config) string {
+	order := "alphabetically"
+	switch analyze.SortMode(cfg.SortMode) {
+	case analyze.SortCount:
+		order = "by count"
+	case analyze.SortLength:
+		order = "by length"
 	}
-	// Use Fprintln to add the newline exactly like wc does
-	fmt.Fprintln(w)
+	if cfg.CharFrequency && order != "by count" {
+		order = "by code point"
+	}
+	if cfg.Reverse {
+		order += ", reversed"
+	}
+	return order
 }
 
-// processFileForCounting handles standard counting operations for a specific file
-// returns lineCount, wordCount, charCount, and error
-func processFileForCounting(path string, cfg *Config) (int, int, int, error) {
-	// Open the file
-	file, err := os.Open(path)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to open file %s: %w", path, err)
+// printFrequencies sorts and limits counts as cfg asks and prints them as a
+// two-column table. A non-empty note is added to the header, as in
+// "Word frequency (3 files combined, sorted by count):".
+func printFrequencies(cfg *Config, counts frequencyCounts, note string) {
+	if note != "" {
+		note += ", "
 	}
-	defer file.Close()
 	
-	// Read the file contents to handle multiple passes
-	fileContents, err := io.ReadAll(file)
-	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to read file %s: %w", path, err) 
+	if cfg.CharFrequency {
+		printCharFrequencies(cfg, analyze.SortCharFrequencies(counts.chars, analyze.SortMode(cfg.SortMode), frequencyLimit(cfg), frequencyOptions(cfg)), note)
+		return
 	}
 	
-	// Set up various counts
-	var lineCount, wordCount, charCount int
+	frequencies := analyze.SortFrequencies(counts.words, analyze.SortMode(cfg.SortMode), frequencyLimit(cfg), frequencyOptions(cfg))
 	
-	// If default behavior (like wc), show all three counts
-	if cfg.Line && cfg.Word && cfg.Char {
-		lineCount = countLines(bytes.NewReader(fileContents))
-		wordCount = countWords(bytes.NewReader(fileContents))
-		charCount = countChars(bytes.NewReader(fileContents))
-		
-		// Use our wc-like formatter
-		FormatLikeWC(cfg.Output, lineCount, wordCount, charCount, path)
-		return lineCount, wordCount, charCount, nil
+	// Determine the longest word to format output nicely, in runes as the
+	// padding is, so multibyte words don't widen the column
+	maxWordLen := 0
+	for _, wf := range frequencies {
+		if n := len([]rune(wf.Word)); n > maxWordLen {
+			maxWordLen = n
+		}
 	}
 	
-	// Otherwise handle individual flags
-	var count int
-	switch {
-	case cfg.Line:
-		count = countLines(bytes.NewReader(fileContents))
-		lineCount = count
-	case cfg.Char:
-		count = countChars(bytes.NewReader(fileContents))
-		charCount = count
-	case cfg.Word:
-		count = countWords(bytes.NewReader(fileContents))
-		wordCount = count
+	// Cap the word column so one outlier token doesn't blow out the table
+	if cfg.MaxColWidth > 0 && maxWordLen > cfg.MaxColWidth {
+		maxWordLen = cfg.MaxColWidth
 	}
 	
-	// Print with filename, using the same spacing as wc
-	fmt.Fprintf(cfg.Output, "%8d %s\n", count, path)
+	// Word clouds want bare word:weight pairs rather than a table
+	if cfg.Cloud {
+		printCloud(cfg, frequencies)
+		return
+	}
 	
-	return lineCount, wordCount, charCount, nil
+	// Print the unique word count above the table
+	if cfg.UniqueWords {
+		fmt.Fprintf(cfg.Output, "Unique words: %d\n", len(counts.distinct))
+	}
+	
+	// --percent adds each word's share of every word counted, not just those shown
+	total := 0
+	if cfg.ShowPercent {
+		for _, count := range counts.words {
+			total += count
+		}
+	}
+	
+	// --percent and --with-position each add a column after the count
+	extraColumns := func(wf analyze.WordFrequency) []string {
+		var columns []string
+		if cfg.ShowPercent {
+			columns = append(columns, fmt.Sprintf("%5.1f%%", percentOf(wf.Count, total)))
+		}
+		if cfg.WithPosition {
+			columns = append(columns, fmt.Sprintf("%6d", counts.firstSeen[wf.Word]))
+		}
+		return columns
+	}
+	
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for _, wf := range frequencies {
+			fields := []string{wf.Word, formatCount(cfg, wf.Count)}
+			for _, column := range extraColumns(wf) {
+				fields = append(fields, strings.TrimSpace(column))
+			}
+			fmt.Fprintln(cfg.Output, strings.Join(fields, " "))
+		}
+		return
+	}
+	
+	// Print header, unless --no-header asks for just the rows
+	if !cfg.NoHeader {
+		label := "Word"
+		switch {
+		case cfg.SuffixLen > 0:
+			label = fmt.Sprintf("%d-character suffix", cfg.SuffixLen)
+		case cfg.PrefixLen > 0:
+			label = fmt.Sprintf("%d-character prefix", cfg.PrefixLen)
+		case cfg.NgramSize > 1:
+			label = fmt.Sprintf("%d-gram", cfg.NgramSize)
+		}
+		fmt.Fprintf(cfg.Output, "%s frequency (%ssorted %s):\n", label, note, sortOrder(cfg))
+		
+		// Print a separator line, with one more dash group for each extra column
+		separator := []string{strings.Repeat("-", maxWordLen), "------"}
+		if cfg.ShowPercent {
+			separator = append(separator, "------")
+		}
+		if cfg.WithPosition {
+			separator = append(separator, "------")
+		}
+		fmt.Fprintln(cfg.Output, strings.Join(separator, "  "))
+	}
+	
+	// Print the results in a nicely formatted column layout
+	for _, wf := range frequencies {
+		row := fmt.Sprintf("%-*s  %6s", maxWordLen, truncateWord(wf.Word, cfg.MaxColWidth), formatCount(cfg, wf.Count))
+		for _, column := range extraColumns(wf) {
+			row += "  " + column
+		}
+		fmt.Fprintln(cfg.Output, row)
+	}
 }
 
-// processFileForFrequency handles word frequency analysis for a specific file
-func processFileForFrequency(path string, cfg *Config) error {
-	// Open the file
-	file, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", path, err)
+// percentOf returns count as a percentage of total, or 0 when total is 0
+func percentOf(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(count) / float64(total)
+}
+
+// printCloud prints each word with its count scaled to a weight from 1 for
+// the least frequent word shown to 100 for the most frequent
+func printCloud(cfg *Config, frequencies []analyze.WordFrequency) {
+	if len(frequencies) == 0 {
+		return
 	}
-	defer file.Close()
 	
-	// If multiple files, print the filename
-	if len(cfg.Paths) > 1 {
-		fmt.Fprintf(cfg.Output, "%s:\n", path)
+	least, most := frequencies[0].Count, frequencies[0].Count
+	for _, wf := range frequencies {
+		if wf.Count < least {
+			least = wf.Count
+		}
+		if wf.Count > most {
+			most = wf.Count
+		}
 	}
 	
-	// Process the file
-	return processReaderForFrequency(file, cfg)
+	for _, wf := range frequencies {
+		fmt.Fprintf(cfg.Output, "%s:%d\n", wf.Word, cloudWeight(wf.Count, least, most))
+	}
 }
 
-// processReaderForFrequency handles word frequency analysis for any io.Reader
-func processReaderForFrequency(r io.Reader, cfg *Config) error {
-	// Analyze word frequency
-	frequencies, err := analyzeWordFrequency(r, cfg.SortByCount, cfg.FrequencyLimit)
-	if err != nil {
-		return fmt.Errorf("failed to analyze word frequency: %w", err)
+// cloudWeight scales count from the range least to most onto 1 to 100,
+// giving every word 100 when they all have the same count
+func cloudWeight(count, least, most int) int {
+	if least == most {
+		return 100
+	}
+	return 1 + int(math.Round(99*float64(count-least)/float64(most-least)))
+}
+
+// printCharFrequencies prints sorted character frequencies in the same layout as word frequency
+func printCharFrequencies(cfg *Config, frequencies []analyze.CharFrequency, note string) {
+	// Render every character first so the column can fit the widest one
+	chars := make([]string, len(frequencies))
+	maxCharLen := 0
+	for i, cf := range frequencies {
+		chars[i] = displayChar(cf.Char)
+		if n := len([]rune(chars[i])); n > maxCharLen {
+			maxCharLen = n
+		}
 	}
 	
-	// Determine the longest word to format output nicely
-	maxWordLen := 0
-	for _, wf := range frequencies {
-		if len(wf.Word) > maxWordLen {
-			maxWordLen = len(wf.Word)
+	// Quiet output is just the rows, separated by a single space
+	if cfg.Quiet {
+		for i, cf := range frequencies {
+			if cfg.TopChars > 0 {
+				fmt.Fprintf(cfg.Output, "%s U+%04X %s\n", chars[i], cf.Char, formatCount(cfg, cf.Count))
+				continue
+			}
+			fmt.Fprintf(cfg.Output, "%s %s\n", chars[i], formatCount(cfg, cf.Count))
 		}
+		return
 	}
 	
-	// Print header
-	if cfg.SortByCount {
-		fmt.Fprintf(cfg.Output, "Word frequency (sorted by count):\n")
-	} else {
-		fmt.Fprintf(cfg.Output, "Word frequency (sorted alphabetically):\n")
+	// Print header, unless --no-header asks for just the rows
+	if !cfg.NoHeader {
+		fmt.Fprintf(cfg.Output, "Character frequency (%ssorted %s):\n", note, sortOrder(cfg))
+	}
+	
+	// --top-chars adds a column with each character's code point
+	if cfg.TopChars > 0 {
+		if !cfg.NoHeader {
+			fmt.Fprintf(cfg.Output, "%s  %s  %s\n", strings.Repeat("-", maxCharLen), "--------", "------")
+		}
+		for i, cf := range frequencies {
+			fmt.Fprintf(cfg.Output, "%-*s  %-8s  %6s\n", maxCharLen, chars[i], fmt.Sprintf("U+%04X", cf.Char), formatCount(cfg, cf.Count))
+		}
+		return
 	}
 	
 	// Print a separator line
-	fmt.Fprintf(cfg.Output, "%s  %s\n", strings.Repeat("-", maxWordLen), "------")
+	if !cfg.NoHeader {
+		fmt.Fprintf(cfg.Output, "%s  %s\n", strings.Repeat("-", maxCharLen), "------")
+	}
 	
-	// Print the results in a nicely formatted two-column layout
-	for _, wf := range frequencies {
-		fmt.Fprintf(cfg.Output, "%-*s  %6d\n", maxWordLen, wf.Word, wf.Count)
+	// Print the results in the same two-column layout as word frequency
+	for i, cf := range frequencies {
+		fmt.Fprintf(cfg.Output, "%-*s  %6s\n", maxCharLen, chars[i], formatCount(cfg, cf.Count))
+	}
+}
+
+// frequencyLimit returns the number of frequency rows to show, defaulting to 10
+func frequencyLimit(cfg *Config) int {
+	if cfg.FrequencyLimit <= 0 {
+		return 10
+	}
+	return cfg.FrequencyLimit
+}
+
+// displayChar renders a rune for the character frequency table, using its
+// U+XXXX code point when it wouldn't be visible (control characters and whitespace)
+func displayChar(c rune) string {
+	if unicode.IsPrint(c) && !unicode.IsSpace(c) {
+		return string(c)
+	}
+	return fmt.Sprintf("U+%04X", c)
+}
+
+// truncateWord shortens a word to at most width runes, marking the cut with an ellipsis.
+// A width of 0 or less leaves the word untouched.
+func truncateWord(word string, width int) string {
+	runes := []rune(word)
+	if width <= 0 || len(runes) <= width {
+		return word
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// waitsOnTerminal reports whether cfg reads its input from stdin while stdin is
+// an interactive terminal, so lexo would block until the user typed something
+// and pressed Ctrl-D. --repl is meant to be typed into, so it never waits.
+func waitsOnTerminal(cfg *Config) bool {
+	readsStdin := (len(cfg.Paths) == 0 && cfg.FilesFrom == "" && !cfg.LOC && cfg.ManifestPath == "") || cfg.FilesFrom == "-"
+	if cfg.REPL || !readsStdin {
+		return false
 	}
 	
-	return nil
+	return isTerminal(cfg.Input)
+}
+
+// isTerminal reports whether r is an interactive terminal rather than a pipe,
+// a redirected file or some other reader
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 // Allow os.Exit to be mocked in tests
@@ -872,8 +3405,26 @@ func main() {
 	// Create default configuration
 	cfg := NewDefaultConfig()
 	
+	// Load default flags from .lexorc, which the command line overrides
+	if err := loadConfigFile(cfg); err != nil {
+		fmt.Fprintf(cfg.ErrorOutput, "Error: %v\n", err)
+		osExit(1)
+		return
+	}
+	
 	// Parse command-line flags
-	ParseFlags(cfg)
+	if err := ParseFlags(cfg); err != nil {
+		fmt.Fprintf(cfg.ErrorOutput, "Error: %v\n", err)
+		osExit(1)
+		return
+	}
+	
+	// Show the usage rather than sit waiting for input nobody is going to type
+	if waitsOnTerminal(cfg) {
+		printUsage(cfg.ErrorOutput)
+		osExit(1)
+		return
+	}
 	
 	// Run the program
 	if err := Run(cfg); err != nil {
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// countObject is one input's counts for --ndjson and --json-out
+type countObject struct {
+	Path  string `json:"path,omitempty"`
+	Lines int    `json:"lines"`
+	Words int    `json:"words"`
+	Chars int    `json:"chars"`
+}
+
+// frequencyObject is one word's (or character's) count for --ndjson and --json-out
+type frequencyObject struct {
+	Word  string `json:"word,omitempty"`
+	Char  string `json:"char,omitempty"`
+	Count int    `json:"count"`
+	Path  string `json:"path,omitempty"`
+}
+
+// languageObject is one input's detected language for --ndjson and --json-out
+type languageObject struct {
+	Path       string  `json:"path,omitempty"`
+	Language   string  `json:"language"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// writeCountObjects passes the line, word and character counts of each input,
+// or the single count selected in cfg, to emit as soon as that input has been read
+func writeCountObjects(stdin io.Reader, cfg *Config, emit func(v interface{}) error) error {
+	allCounts := cfg.Line && cfg.Word && cfg.Char
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		if !allCounts {
+			count, err := countSingle(r, cfg)
+			if err != nil {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+			object := map[string]interface{}{countName(cfg): count}
+			if path != "" {
+				object["path"] = path
+			}
+			return emit(object)
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		return emit(countObject{
+			Path:  path,
+			Lines: analyze.CountLines(bytes.NewReader(data)),
+			Words: countWords(bytes.NewReader(data), cfg),
+			Chars: countChars(bytes.NewReader(data), cfg),
+		})
+	})
+}
+
+// writeFrequencyObjects passes word, n-gram or character frequencies to emit
+// one word (or character) at a time, tagged with the path of its input unless
+// the inputs are combined
+func writeFrequencyObjects(stdin io.Reader, cfg *Config, emit func(v interface{}) error) error {
+	if cfg.Combined {
+		counts, err := combinedFrequencies(stdin, cfg)
+		if err != nil {
+			return err
+		}
+		return emitFrequencies(emit, counts, "", cfg)
+	}
+
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		counts, err := countFrequencies(r, cfg)
+		if err != nil {
+			return err
+		}
+		return emitFrequencies(emit, counts, path, cfg)
+	})
+}
+
+// emitFrequencies sorts and limits counts as cfg asks and passes each result
+// to emit as its own object
+func emitFrequencies(emit func(v interface{}) error, counts frequencyCounts, path string, cfg *Config) error {
+	if cfg.CharFrequency {
+		for _, cf := range analyze.SortCharFrequencies(counts.chars, analyze.SortMode(cfg.SortMode), frequencyLimit(cfg), frequencyOptions(cfg)) {
+			if err := emit(frequencyObject{Char: string(cf.Char), Count: cf.Count, Path: path}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, wf := range analyze.SortFrequencies(counts.words, analyze.SortMode(cfg.SortMode), frequencyLimit(cfg), frequencyOptions(cfg)) {
+		if err := emit(frequencyObject{Word: wf.Word, Count: wf.Count, Path: path}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLanguageObjects passes the language detected in each input to emit
+func writeLanguageObjects(stdin io.Reader, cfg *Config, emit func(v interface{}) error) error {
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		tag, name, confidence, err := analyze.DetectLanguage(r, languageOptions(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to detect language: %w", err)
+		}
+		return emit(languageObject{
+			Path:       path,
+			Language:   tag,
+			Name:       name,
+			Confidence: confidence,
+		})
+	})
+}
+
+// writeObjects passes the results of the counting, frequency or language
+// analysis cfg asks for to emit
+func writeObjects(stdin io.Reader, cfg *Config, emit func(v interface{}) error) error {
+	switch {
+	case cfg.DetectLanguage:
+		return writeLanguageObjects(stdin, cfg, emit)
+	case cfg.FrequencyAnalysis || cfg.CharFrequency:
+		return writeFrequencyObjects(stdin, cfg, emit)
+	default:
+		return writeCountObjects(stdin, cfg, emit)
+	}
+}
+
+// writeNDJSON writes the results as one JSON object per line, writing each
+// one as soon as it's ready so consumers can process them as they arrive
+func writeNDJSON(stdin io.Reader, cfg *Config) error {
+	return writeObjects(stdin, cfg, json.NewEncoder(cfg.Output).Encode)
+}
+
+// writeJSONOut writes the results as a JSON array to cfg.JSONOutPath, for
+// --json-out alongside the usual output
+func writeJSONOut(stdin io.Reader, cfg *Config) error {
+	// Start from an empty array so no results still make valid JSON
+	results := []interface{}{}
+	err := writeObjects(stdin, cfg, func(v interface{}) error {
+		results = append(results, v)
+		return nil
+	})
+	// With --keep-going the files that failed are left out and the results
+	// for the rest are still written. The usual output reports the failures.
+	if err != nil && !cfg.KeepGoing {
+		return err
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+
+	if err := os.WriteFile(cfg.JSONOutPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cfg.JSONOutPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+)
+
+// asciiReplacements maps the typographic punctuation and spaces word
+// processors write to the ASCII characters --normalize counts them as
+var asciiReplacements = map[rune]rune{
+	'‘': '\'', // Left single quote
+	'’': '\'', // Right single quote and apostrophe
+	'‚': '\'', // Low single quote
+	'‛': '\'', // Reversed single quote
+	'′': '\'', // Prime
+	'“': '"',  // Left double quote
+	'”': '"',  // Right double quote
+	'„': '"',  // Low double quote
+	'‟': '"',  // Reversed double quote
+	'″': '"',  // Double prime
+	'‐': '-',  // Hyphen
+	'‑': '-',  // Non-breaking hyphen
+	'‒': '-',  // Figure dash
+	'–': '-',  // En dash
+	'—': '-',  // Em dash
+	'―': '-',  // Horizontal bar
+	'−': '-',  // Minus sign
+	' ': ' ',  // No-break space
+	' ': ' ',  // Figure space
+	' ': ' ',  // Narrow no-break space
+}
+
+// normalizeASCII replaces smart quotes, dashes and non-breaking spaces in r
+// with their ASCII equivalents as it's read
+func normalizeASCII(r io.Reader) io.Reader {
+	return transform.NewReader(r, runes.Map(func(c rune) rune {
+		if replacement, ok := asciiReplacements[c]; ok {
+			return replacement
+		}
+		return c
+	}))
+}
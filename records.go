@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// recordWriter writes rows of delimited output for --csv and --tsv
+type recordWriter struct {
+	csv *csv.Writer
+	tsv io.Writer
+}
+
+// newRecordWriter creates a record writer for the output format selected in cfg
+func newRecordWriter(cfg *Config) *recordWriter {
+	if cfg.TSVOutput {
+		return &recordWriter{tsv: cfg.Output}
+	}
+	return &recordWriter{csv: csv.NewWriter(cfg.Output)}
+}
+
+// Write writes a single row. CSV fields are quoted as needed, while TSV
+// fields are written as-is so the output stays easy to split with cut or awk.
+func (rw *recordWriter) Write(fields ...string) error {
+	if rw.tsv != nil {
+		_, err := fmt.Fprintln(rw.tsv, strings.Join(fields, "\t"))
+		return err
+	}
+	return rw.csv.Write(fields)
+}
+
+// Flush writes any buffered rows and reports the first error encountered
+func (rw *recordWriter) Flush() error {
+	if rw.tsv != nil {
+		return nil
+	}
+	rw.csv.Flush()
+	return rw.csv.Error()
+}
+
+// forEachInput calls fn with the prepared reader and path of each input:
+// every file in cfg.Paths, or stdin (with an empty path) if there are none
+func forEachInput(stdin io.Reader, cfg *Config, fn func(r io.Reader, path string) error) error {
+	if len(cfg.Paths) == 0 {
+		return fn(stdin, "")
+	}
+	return forEachRawInput(stdin, cfg, func(r io.Reader, path string) error {
+		return fn(prepareReader(r, cfg), path)
+	})
+}
+
+// forEachRawInput is forEachInput without any preprocessing of the files, for
+// reports on the inputs as they're stored, such as --bom
+func forEachRawInput(stdin io.Reader, cfg *Config, fn func(r io.Reader, path string) error) error {
+	if len(cfg.Paths) == 0 {
+		return fn(stdin, "")
+	}
+
+	errs := &fileErrors{cfg: cfg}
+	for _, path := range cfg.Paths {
+		file, err := openPath(path)
+		if err != nil {
+			if err := errs.check(err); err != nil {
+				return err
+			}
+			continue
+		}
+		err = fn(file, path)
+		file.Close()
+		if err := errs.check(err); err != nil {
+			return err
+		}
+	}
+	return errs.err()
+}
+
+// combinedFrequencies adds up the frequency counts of every input for --combined
+func combinedFrequencies(stdin io.Reader, cfg *Config) (frequencyCounts, error) {
+	var total frequencyCounts
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		counts, err := countFrequencies(r, cfg)
+		if err != nil {
+			return err
+		}
+		total.add(counts)
+		return nil
+	})
+	return total, err
+}
+
+// writeCountRecords writes the line, word and character counts of each input,
+// or the single count selected in cfg, as one record per input under a single
+// header
+func writeCountRecords(stdin io.Reader, cfg *Config) error {
+	allCounts := cfg.Line && cfg.Word && cfg.Char
+	header := []string{"lines", "words", "chars", "path"}
+	if !allCounts {
+		header = []string{countName(cfg), "path"}
+	}
+	rw := newRecordWriter(cfg)
+	if err := rw.Write(header...); err != nil {
+		return err
+	}
+
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		if !allCounts {
+			count, err := countSingle(r, cfg)
+			if err != nil {
+				return fmt.Errorf("failed to read input: %w", err)
+			}
+			return rw.Write(strconv.Itoa(count), path)
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		return rw.Write(
+			strconv.Itoa(analyze.CountLines(bytes.NewReader(data))),
+			strconv.Itoa(countWords(bytes.NewReader(data), cfg)),
+			strconv.Itoa(countChars(bytes.NewReader(data), cfg)),
+			path,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	return rw.Flush()
+}
+
+// writeFrequencyRecords writes word, n-gram or character frequencies as
+// records under a single header, adding a path column for multiple files
+// unless they're combined into one table
+func writeFrequencyRecords(stdin io.Reader, cfg *Config) error {
+	header := []string{"word", "count"}
+	if cfg.CharFrequency {
+		header[0] = "char"
+	}
+	withPath := len(cfg.Paths) > 1 && !cfg.Combined
+	if withPath {
+		header = append(header, "path")
+	}
+
+	rw := newRecordWriter(cfg)
+	if err := rw.Write(header...); err != nil {
+		return err
+	}
+
+	if cfg.Combined {
+		counts, err := combinedFrequencies(stdin, cfg)
+		if err != nil {
+			return err
+		}
+		for _, row := range frequencyRows(counts, cfg) {
+			if err := rw.Write(row...); err != nil {
+				return err
+			}
+		}
+		return rw.Flush()
+	}
+
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		counts, err := countFrequencies(r, cfg)
+		if err != nil {
+			return err
+		}
+		for _, row := range frequencyRows(counts, cfg) {
+			if withPath {
+				row = append(row, path)
+			}
+			if err := rw.Write(row...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return rw.Flush()
+}
+
+// frequencyRows sorts and limits counts as cfg asks and
+// returns each result as a word (or character) and count pair
+func frequencyRows(counts frequencyCounts, cfg *Config) [][]string {
+	var rows [][]string
+
+	if cfg.CharFrequency {
+		for _, cf := range analyze.SortCharFrequencies(counts.chars, analyze.SortMode(cfg.SortMode), frequencyLimit(cfg), frequencyOptions(cfg)) {
+			rows = append(rows, []string{displayChar(cf.Char), strconv.Itoa(cf.Count)})
+		}
+		return rows
+	}
+
+	for _, wf := range analyze.SortFrequencies(counts.words, analyze.SortMode(cfg.SortMode), frequencyLimit(cfg), frequencyOptions(cfg)) {
+		rows = append(rows, []string{wf.Word, strconv.Itoa(wf.Count)})
+	}
+	return rows
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sampleReader cuts r off after the first cfg.SampleBytes bytes and
+// cfg.SampleLines lines, whichever comes first, for --sample and
+// --sample-lines. Without either r is returned as it is.
+func sampleReader(r io.Reader, cfg *Config) io.Reader {
+	if cfg.SampleLines > 0 {
+		r = &lineLimitReader{r: r, remaining: cfg.SampleLines}
+	}
+	if cfg.SampleBytes > 0 {
+		r = io.LimitReader(r, int64(cfg.SampleBytes))
+	}
+	return r
+}
+
+// lineLimitReader reads from r until it has returned remaining lines,
+// including the last one's newline
+type lineLimitReader struct {
+	r         io.Reader
+	remaining int
+}
+
+func (l *lineLimitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	n, err := l.r.Read(p)
+	for i := 0; i < n; {
+		nl := bytes.IndexByte(p[i:n], '\n')
+		if nl < 0 {
+			break
+		}
+		i += nl + 1
+		l.remaining--
+		if l.remaining == 0 {
+			return i, nil
+		}
+	}
+	return n, err
+}
+
+// sampleNote says how much of each input was analyzed when only a sample
+// was, so the results aren't mistaken for exact ones
+func sampleNote(cfg *Config) string {
+	var limits []string
+	if cfg.SampleLines > 0 {
+		limits = append(limits, fmt.Sprintf("%d lines", cfg.SampleLines))
+	}
+	if cfg.SampleBytes > 0 {
+		limits = append(limits, fmt.Sprintf("%d bytes", cfg.SampleBytes))
+	}
+	if len(limits) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(sampled first %s of each input)", strings.Join(limits, " or "))
+}
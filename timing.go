@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// fileTimer times each input Run processes for --timing, writing one line
+// per input and a total to cfg.ErrorOutput so the analysis output on
+// cfg.Output is unchanged
+type fileTimer struct {
+	cfg    *Config
+	total  time.Duration
+	inputs int
+}
+
+// time runs fn, which processes the input at path (empty for stdin), and
+// reports how long it took when cfg.Timing is set
+func (ft *fileTimer) time(path string, fn func() error) error {
+	if !ft.cfg.Timing {
+		return fn()
+	}
+
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	ft.total += elapsed
+	ft.inputs++
+
+	if path == "" {
+		path = "stdin"
+	}
+	fmt.Fprintf(ft.cfg.ErrorOutput, "%12s %s\n", elapsed.Round(time.Microsecond), path)
+	return err
+}
+
+// printTotal reports the time taken by all the inputs when cfg.Timing is set
+// and there was more than one
+func (ft *fileTimer) printTotal() {
+	if ft.cfg.Timing && ft.inputs > 1 {
+		fmt.Fprintf(ft.cfg.ErrorOutput, "%12s total\n", ft.total.Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"cloudartisan.com/lexo/analyze"
+)
+
+// printTokens writes the words of each input to cfg.Output one per line, in
+// the order they appear, normalized as word frequency analysis normalizes them
+func printTokens(stdin io.Reader, cfg *Config) error {
+	out := bufio.NewWriter(cfg.Output)
+	err := forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		err := analyze.Tokens(r, frequencyOptions(cfg), func(word string) {
+			fmt.Fprintln(out, word)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read words: %w", err)
+		}
+		return nil
+	})
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
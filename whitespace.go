@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// whitespaceReport counts the lines in r that end in whitespace and the
+// lines indented with both tabs and spaces. Line endings, CRLF included,
+// don't count as trailing whitespace.
+func whitespaceReport(r io.Reader) (trailing, mixedIndent int, err error) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if strings.TrimRightFunc(line, unicode.IsSpace) != line {
+				trailing++
+			}
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			if strings.Contains(indent, " ") && strings.Contains(indent, "\t") {
+				mixedIndent++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return trailing, mixedIndent, nil
+}
+
+// runWhitespaceReport prints the trailing whitespace and mixed indentation
+// counts of each input, under its path when there are several
+func runWhitespaceReport(stdin io.Reader, cfg *Config) error {
+	return forEachInput(stdin, cfg, func(r io.Reader, path string) error {
+		trailing, mixedIndent, err := whitespaceReport(r)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		if len(cfg.Paths) > 1 {
+			fmt.Fprintf(cfg.Output, "%s:\n", path)
+		}
+		fmt.Fprintf(cfg.Output, "Lines with trailing whitespace: %d\n", trailing)
+		fmt.Fprintf(cfg.Output, "Lines with mixed tab/space indentation: %d\n", mixedIndent)
+		return nil
+	})
+}